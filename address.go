@@ -0,0 +1,113 @@
+package blockutils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The version bytes a network prefixes the hashes in its Base58Check
+// addresses with, as base58Prefixes in bitcoin's chainparams.cpp, and the
+// human readable part of its segwit addresses. Networks without segwit
+// have no Bech32HRP
+type AddressParams struct {
+	PubKeyHashPrefix byte
+	ScriptHashPrefix byte
+	Bech32HRP        string
+}
+
+var (
+	AddressParamsBitcoin        = AddressParams{PubKeyHashPrefix: 0x00, ScriptHashPrefix: 0x05, Bech32HRP: "bc"}
+	AddressParamsBitcoinTestnet = AddressParams{PubKeyHashPrefix: 0x6f, ScriptHashPrefix: 0xc4, Bech32HRP: "tb"}
+	AddressParamsBitcoinRegtest = AddressParams{PubKeyHashPrefix: 0x6f, ScriptHashPrefix: 0xc4, Bech32HRP: "bcrt"}
+	AddressParamsLitecoin       = AddressParams{PubKeyHashPrefix: 0x30, ScriptHashPrefix: 0x32, Bech32HRP: "ltc"}
+	AddressParamsDogecoin       = AddressParams{PubKeyHashPrefix: 0x1e, ScriptHashPrefix: 0x16}
+	AddressParamsDigiByte       = AddressParams{PubKeyHashPrefix: 0x1e, ScriptHashPrefix: 0x3f, Bech32HRP: "dgb"}
+	AddressParamsBitcoinCash    = AddressParams{PubKeyHashPrefix: 0x00, ScriptHashPrefix: 0x05}
+)
+
+// Returned when a script has no address form, such as nulldata and
+// nonstandard scripts
+var ErrNoAddress = errors.New("Script has no address")
+
+// Returned when an address can not be decoded for the given network
+var ErrInvalidAddress = errors.New("Invalid address")
+
+// Returns the address the script pays to, as ExtractDestination and
+// EncodeDestination in bitcoin. Pay to pubkey scripts are shown as the
+// address of the key's hash, as bitcoin does. Witness programs of any
+// version are shown as segwit addresses
+func (script Script) Address(params *AddressParams) (string, error) {
+	scriptType, solutions := script.Solve()
+	switch scriptType {
+	case ScriptPubKey:
+		return encodeBase58Address(params.PubKeyHashPrefix, Hash160(solutions[0])), nil
+	case ScriptPubKeyHash:
+		return encodeBase58Address(params.PubKeyHashPrefix, solutions[0]), nil
+	case ScriptScriptHash:
+		return encodeBase58Address(params.ScriptHashPrefix, solutions[0]), nil
+	case ScriptWitnessV0KeyHash, ScriptWitnessV0ScriptHash, ScriptWitnessV1Taproot, ScriptAnchor, ScriptWitnessUnknown:
+		if params.Bech32HRP == "" {
+			return "", fmt.Errorf("%s script on a network without segwit: %w", scriptType, ErrNoAddress)
+		}
+		version, program, _ := script.witnessProgram()
+		return EncodeSegwitAddress(params.Bech32HRP, version, program)
+	}
+
+	return "", fmt.Errorf("%s script: %w", scriptType, ErrNoAddress)
+}
+
+// Returns the output script paying to address on the given network
+func AddressToScript(address string, params *AddressParams) (Script, error) {
+	if params.Bech32HRP != "" && strings.HasPrefix(strings.ToLower(address), params.Bech32HRP+"1") {
+		version, program, err := DecodeSegwitAddress(params.Bech32HRP, address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, err)
+		}
+		return newWitnessScript(version, program), nil
+	}
+
+	data, err := Base58CheckDecode(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, err)
+	}
+	if len(data) != 21 {
+		return nil, fmt.Errorf("%w: invalid length %d", ErrInvalidAddress, len(data))
+	}
+
+	switch data[0] {
+	case params.PubKeyHashPrefix:
+		return newP2PKHScript(data[1:]), nil
+	case params.ScriptHashPrefix:
+		return newP2SHScript(data[1:]), nil
+	}
+	return nil, fmt.Errorf("%w: unknown version byte 0x%02x", ErrInvalidAddress, data[0])
+}
+
+func encodeBase58Address(version byte, hash []byte) string {
+	return Base58CheckEncode(append([]byte{version}, hash...))
+}
+
+// Returns "OP_DUP OP_HASH160 <hash> OP_EQUALVERIFY OP_CHECKSIG"
+func newP2PKHScript(hash []byte) Script {
+	script := Script{OP_DUP, OP_HASH160}
+	script = appendPushData(script, hash)
+	return append(script, OP_EQUALVERIFY, OP_CHECKSIG)
+}
+
+// Returns "OP_HASH160 <hash> OP_EQUAL"
+func newP2SHScript(hash []byte) Script {
+	script := Script{OP_HASH160}
+	script = appendPushData(script, hash)
+	return append(script, OP_EQUAL)
+}
+
+// Returns "<version> <program>", using OP_0 or OP_1 to OP_16 for the
+// version
+func newWitnessScript(version byte, program []byte) Script {
+	script := Script{OP_0}
+	if version > 0 {
+		script[0] = OP_1 + version - 1
+	}
+	return appendPushData(script, program)
+}
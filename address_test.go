@@ -0,0 +1,81 @@
+package blockutils
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+type testpairaddress struct {
+	script  string
+	params  AddressParams
+	address string
+}
+
+func TestScriptAddress(t *testing.T) {
+	var tests = []testpairaddress{
+		{"76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", AddressParamsBitcoin, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", AddressParamsBitcoin, "38XEixUj1QpcqxTWbxvqdbv4Mjre4imw9Z"},
+		{"76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac", AddressParamsBitcoinTestnet, "mxoz69oHz5unGTm8u1xskWNeakganmbU1V"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", AddressParamsBitcoinTestnet, "2Mz5SnhQkcsKy3k64H6YiFYuKa64ondmQnD"},
+		{"76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac", AddressParamsLitecoin, "LcWz4K29Fiiak9ygMayoCcE5vyTA1cyENi"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", AddressParamsLitecoin, "MEjP2qtgxXg3eTjQhqvBTFATgST626wb4B"},
+		{"76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac", AddressParamsDogecoin, "DNS8LMexUUNp2MU7v2z4UMKvbtpBCh9kyh"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", AddressParamsDogecoin, "9yGVToYd5UhWkKpz26bFsjYS4KEg5Bjwwj"},
+		{"76a914b788297cf734149f6225228c50ff905917aa8f4088ac", AddressParamsDigiByte, "DMsXK4yjbjCikTE6DM9bL8AaQbN3YKq1BY"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", AddressParamsDigiByte, "SU8DqFmSBshSH6YY2JFKkshgrzoMGU9obQ"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", AddressParamsBitcoinCash, "38XEixUj1QpcqxTWbxvqdbv4Mjre4imw9Z"},
+	}
+
+	for _, pair := range tests {
+		script, _ := hex.DecodeString(pair.script)
+		address, err := Script(script).Address(&pair.params)
+		if err != nil {
+			t.Errorf("Could not get address for %s: %s", pair.script, err)
+		}
+		if address != pair.address {
+			t.Errorf("Incorrect address for %s. Expected %s, got %s", pair.script, pair.address, address)
+		}
+
+		decoded, err := AddressToScript(pair.address, &pair.params)
+		if err != nil {
+			t.Errorf("Could not decode %s: %s", pair.address, err)
+		}
+		if decoded.String() != pair.script {
+			t.Errorf("Incorrect script for %s. Expected %s, got %s", pair.address, pair.script, decoded)
+		}
+	}
+}
+
+func TestScriptAddressP2PK(t *testing.T) {
+	// The genesis block coinbase output
+	script, _ := hex.DecodeString("4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac")
+	address, err := Script(script).Address(&AddressParamsBitcoin)
+	if err != nil {
+		t.Errorf("Could not get address: %s", err)
+	}
+	if address != "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa" {
+		t.Errorf("Incorrect address. Expected %s, got %s", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", address)
+	}
+}
+
+func TestScriptAddressErrors(t *testing.T) {
+	script, _ := hex.DecodeString("6a24aa21a9ed735a4c6d92c7bc860c0558bf0b49feb40e553dffe846613bd6d6bac983473d2c")
+	if _, err := Script(script).Address(&AddressParamsBitcoin); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Incorrect error for nulldata script. Expected %s, got %v", ErrNoAddress, err)
+	}
+
+	var tests = []string{
+		"DMsXK4yjbjCikTE6DM9bL8AaQbN3YKq1BY",
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb",
+		"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfN0",
+		"133VQZmihsauVFjR6XTtJkHs9ZP6Nwkii",
+		"",
+	}
+
+	for _, address := range tests {
+		if _, err := AddressToScript(address, &AddressParamsBitcoin); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("Incorrect error for %s. Expected %s, got %v", address, ErrInvalidAddress, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package blockutils
+
+import (
+	"bytes"
+	"errors"
+)
+
+// The base58 alphabet used by bitcoin, which leaves out 0, O, I and l to
+// avoid confusion between similar looking characters
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Base58Check data ends with the first 4 bytes of its double sha256
+const base58ChecksumLength = 4
+
+// Returned when a string contains characters outside the base58 alphabet
+var ErrInvalidBase58 = errors.New("Invalid base58 string")
+
+// Returned when the checksum of Base58Check data does not match
+var ErrInvalidChecksum = errors.New("Invalid checksum")
+
+// The value of each character of the alphabet, or -1 for characters
+// outside of it
+var base58Values = func() [256]int {
+	var values [256]int
+	for i := range values {
+		values[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		values[base58Alphabet[i]] = i
+	}
+	return values
+}()
+
+// Encodes data as base58, as EncodeBase58 in bitcoin's base58.cpp. Each
+// leading zero byte is encoded as a leading 1
+func Base58Encode(data []byte) string {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros += 1
+	}
+
+	// Convert from base 256 to base 58, most significant digit first.
+	// log(256) / log(58) is about 1.37
+	digits := make([]byte, (len(data)-zeros)*138/100+1)
+	length := 0
+	for _, b := range data[zeros:] {
+		carry := int(b)
+		i := 0
+		for j := len(digits) - 1; (carry != 0 || i < length) && j >= 0; j-- {
+			carry += 256 * int(digits[j])
+			digits[j] = byte(carry % 58)
+			carry /= 58
+			i += 1
+		}
+		length = i
+	}
+
+	encoded := make([]byte, zeros+length)
+	for i := 0; i < zeros; i++ {
+		encoded[i] = '1'
+	}
+	for i, digit := range digits[len(digits)-length:] {
+		encoded[zeros+i] = base58Alphabet[digit]
+	}
+	return string(encoded)
+}
+
+// Decodes a base58 string, as DecodeBase58 in bitcoin's base58.cpp
+func Base58Decode(encoded string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(encoded) && encoded[zeros] == '1' {
+		zeros += 1
+	}
+
+	// Convert from base 58 to base 256. log(58) / log(256) is about 0.733
+	decoded := make([]byte, (len(encoded)-zeros)*733/1000+1)
+	length := 0
+	for i := zeros; i < len(encoded); i++ {
+		carry := base58Values[encoded[i]]
+		if carry < 0 {
+			return nil, ErrInvalidBase58
+		}
+
+		j := 0
+		for k := len(decoded) - 1; (carry != 0 || j < length) && k >= 0; k-- {
+			carry += 58 * int(decoded[k])
+			decoded[k] = byte(carry % 256)
+			carry /= 256
+			j += 1
+		}
+		length = j
+	}
+
+	result := make([]byte, zeros+length)
+	copy(result[zeros:], decoded[len(decoded)-length:])
+	return result, nil
+}
+
+// Encodes data as base58, followed by a checksum, as EncodeBase58Check in
+// bitcoin's base58.cpp. Addresses are Base58Check encoded with a version
+// byte before their hash
+func Base58CheckEncode(data []byte) string {
+	checked := make([]byte, len(data), len(data)+base58ChecksumLength)
+	copy(checked, data)
+	checked = append(checked, DoubleSha256(data)[:base58ChecksumLength]...)
+	return Base58Encode(checked)
+}
+
+// Decodes a Base58Check string, verifying and removing its checksum
+func Base58CheckDecode(encoded string) ([]byte, error) {
+	decoded, err := Base58Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) < base58ChecksumLength {
+		return nil, ErrInvalidChecksum
+	}
+
+	data := decoded[:len(decoded)-base58ChecksumLength]
+	if !bytes.Equal(DoubleSha256(data)[:base58ChecksumLength], decoded[len(data):]) {
+		return nil, ErrInvalidChecksum
+	}
+	return data, nil
+}
@@ -0,0 +1,70 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+type testpairbase58 struct {
+	input  string
+	output string
+}
+
+func TestBase58(t *testing.T) {
+	var tests = []testpairbase58{
+		{"", ""},
+		{"000001", "112"},
+		{"68656c6c6f20776f726c64", "StV1DL6CwTryKyV"},
+		{"00000000000000000000", "1111111111"},
+		{"0062e907b15cbf27d5425399ebf6f0fb50ebb88f18c29b7d93", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+	}
+
+	for _, pair := range tests {
+		data, _ := hex.DecodeString(pair.input)
+		encoded := Base58Encode(data)
+		if encoded != pair.output {
+			t.Errorf("Incorrect base58 for %s. Expected %s, got %s", pair.input, pair.output, encoded)
+		}
+
+		decoded, err := Base58Decode(pair.output)
+		if err != nil {
+			t.Errorf("Could not decode %s: %s", pair.output, err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("Incorrect data for %s. Expected %s, got %x", pair.output, pair.input, decoded)
+		}
+	}
+}
+
+func TestBase58Check(t *testing.T) {
+	data, _ := hex.DecodeString("0062e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+	encoded := Base58CheckEncode(data)
+	if encoded != "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa" {
+		t.Errorf("Incorrect Base58Check. Expected %s, got %s", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", encoded)
+	}
+
+	decoded, err := Base58CheckDecode(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("Incorrect data. Expected %x, got %x (%v)", data, decoded, err)
+	}
+
+	var tests = []struct {
+		input string
+		err   error
+	}{
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", ErrInvalidChecksum},
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfN0", ErrInvalidBase58},
+		{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNI", ErrInvalidBase58},
+		{"1", ErrInvalidChecksum},
+		{"", ErrInvalidChecksum},
+	}
+
+	for _, test := range tests {
+		_, err := Base58CheckDecode(test.input)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Incorrect error for %s. Expected %s, got %v", test.input, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package blockutils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The checksum variant of a bech32 string. BIP173 bech32 is used for
+// version 0 witness programs, and BIP350 bech32m for all later versions
+type Bech32Encoding int
+
+const (
+	Bech32 Bech32Encoding = iota
+	Bech32m
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// The checksum of a valid string, once run through bech32Polymod, is 1
+// for bech32 and this constant for bech32m
+const bech32mConst = 0x2bc830a3
+
+const bech32ChecksumLength = 6
+
+// BIP173 limits bech32 strings to 90 characters
+const bech32MaxLength = 90
+
+// Returned when a string is not valid bech32 or bech32m
+var ErrInvalidBech32 = errors.New("Invalid bech32 string")
+
+// Returned when a bech32 address does not hold a valid witness program
+var ErrInvalidWitnessProgram = errors.New("Invalid witness program")
+
+// Encodes 5 bit values under the human readable part hrp, with the
+// checksum of the given encoding
+func Bech32Encode(hrp string, data []byte, encoding Bech32Encoding) (string, error) {
+	hrp = strings.ToLower(hrp)
+	if len(hrp) < 1 || len(hrp)+1+len(data)+bech32ChecksumLength > bech32MaxLength {
+		return "", fmt.Errorf("Invalid length: %w", ErrInvalidBech32)
+	}
+
+	var encoded strings.Builder
+	encoded.WriteString(hrp)
+	encoded.WriteString("1")
+	checksum := bech32Checksum(hrp, data, encoding)
+	for _, value := range append(append([]byte{}, data...), checksum...) {
+		if value > 31 {
+			return "", fmt.Errorf("Invalid 5 bit value %d: %w", value, ErrInvalidBech32)
+		}
+		encoded.WriteByte(bech32Charset[value])
+	}
+	return encoded.String(), nil
+}
+
+// Decodes a bech32 or bech32m string, returning its lowercase human
+// readable part, its 5 bit values without the checksum, and the encoding
+// its checksum matched
+func Bech32Decode(encoded string) (string, []byte, Bech32Encoding, error) {
+	if len(encoded) > bech32MaxLength {
+		return "", nil, 0, fmt.Errorf("Invalid length: %w", ErrInvalidBech32)
+	}
+
+	lower := strings.ToLower(encoded)
+	if lower != encoded && strings.ToUpper(encoded) != encoded {
+		return "", nil, 0, fmt.Errorf("Mixed case: %w", ErrInvalidBech32)
+	}
+
+	separator := strings.LastIndexByte(lower, '1')
+	if separator < 1 || separator+1+bech32ChecksumLength > len(lower) {
+		return "", nil, 0, fmt.Errorf("Invalid separator position: %w", ErrInvalidBech32)
+	}
+
+	hrp := lower[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, fmt.Errorf("Invalid character in human readable part: %w", ErrInvalidBech32)
+		}
+	}
+
+	data := make([]byte, 0, len(lower)-separator-1)
+	for i := separator + 1; i < len(lower); i++ {
+		value := strings.IndexByte(bech32Charset, lower[i])
+		if value < 0 {
+			return "", nil, 0, fmt.Errorf("Invalid character %q: %w", lower[i], ErrInvalidBech32)
+		}
+		data = append(data, byte(value))
+	}
+
+	var encoding Bech32Encoding
+	switch bech32Polymod(hrp, data) {
+	case 1:
+		encoding = Bech32
+	case bech32mConst:
+		encoding = Bech32m
+	default:
+		return "", nil, 0, ErrInvalidChecksum
+	}
+
+	return hrp, data[:len(data)-bech32ChecksumLength], encoding, nil
+}
+
+// Encodes a witness program as a segwit address, as described in BIP173
+// and BIP350
+func EncodeSegwitAddress(hrp string, version byte, program []byte) (string, error) {
+	if err := checkWitnessProgram(version, program); err != nil {
+		return "", err
+	}
+
+	encoding := Bech32
+	if version > 0 {
+		encoding = Bech32m
+	}
+
+	data := append([]byte{version}, convertBits(program, 8, 5, true)...)
+	return Bech32Encode(hrp, data, encoding)
+}
+
+// Decodes a segwit address, which must have the human readable part hrp,
+// returning its witness version and program
+func DecodeSegwitAddress(hrp string, address string) (byte, []byte, error) {
+	addressHRP, data, encoding, err := Bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if addressHRP != strings.ToLower(hrp) {
+		return 0, nil, fmt.Errorf("Unexpected human readable part %s: %w", addressHRP, ErrInvalidBech32)
+	}
+	if len(data) < 1 {
+		return 0, nil, fmt.Errorf("Missing witness version: %w", ErrInvalidWitnessProgram)
+	}
+
+	version := data[0]
+	if (version == 0) != (encoding == Bech32) {
+		return 0, nil, fmt.Errorf("Wrong checksum variant for witness version %d: %w", version, ErrInvalidWitnessProgram)
+	}
+
+	program := convertBits(data[1:], 5, 8, false)
+	if program == nil {
+		return 0, nil, fmt.Errorf("Invalid padding: %w", ErrInvalidWitnessProgram)
+	}
+	if err := checkWitnessProgram(version, program); err != nil {
+		return 0, nil, err
+	}
+	return version, program, nil
+}
+
+// Checks the witness version and program length limits of BIP141
+func checkWitnessProgram(version byte, program []byte) error {
+	if version > 16 {
+		return fmt.Errorf("Invalid witness version %d: %w", version, ErrInvalidWitnessProgram)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return fmt.Errorf("Invalid program length %d: %w", len(program), ErrInvalidWitnessProgram)
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return fmt.Errorf("Invalid version 0 program length %d: %w", len(program), ErrInvalidWitnessProgram)
+	}
+	return nil
+}
+
+func bech32Polymod(hrp string, data []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	update := func(value byte) {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(value)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+
+	// The human readable part is expanded into its high bits, a zero and
+	// its low bits
+	for i := 0; i < len(hrp); i++ {
+		update(hrp[i] >> 5)
+	}
+	update(0)
+	for i := 0; i < len(hrp); i++ {
+		update(hrp[i] & 31)
+	}
+	for _, value := range data {
+		update(value)
+	}
+	return chk
+}
+
+func bech32Checksum(hrp string, data []byte, encoding Bech32Encoding) []byte {
+	constant := uint32(1)
+	if encoding == Bech32m {
+		constant = bech32mConst
+	}
+
+	values := append(append([]byte{}, data...), make([]byte, bech32ChecksumLength)...)
+	polymod := bech32Polymod(hrp, values) ^ constant
+
+	checksum := make([]byte, bech32ChecksumLength)
+	for i := range checksum {
+		checksum[i] = byte(polymod>>uint(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// Regroups data from fromBits to toBits bit values. When decoding, pad is
+// false, and nil is returned if the leftover bits are not zero padding
+func convertBits(data []byte, fromBits uint, toBits uint, pad bool) []byte {
+	acc := uint32(0)
+	bits := uint(0)
+	maxValue := uint32(1)<<toBits - 1
+
+	result := []byte{}
+	for _, value := range data {
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte((acc>>bits)&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte((acc<<(toBits-bits))&maxValue))
+		}
+	} else if bits >= fromBits || (acc<<(toBits-bits))&maxValue != 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package blockutils
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testpairsegwit struct {
+	address string
+	script  string
+}
+
+// Test vectors from BIP350
+func TestSegwitAddress(t *testing.T) {
+	var tests = []testpairsegwit{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"BC1SW50QGDZ25J", "6002751e"},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", "5210751e76e8199196d454941c45d1b3a323"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+
+	for _, pair := range tests {
+		hrp := "bc"
+		if pair.address[:2] == "tb" {
+			hrp = "tb"
+		}
+
+		version, program, err := DecodeSegwitAddress(hrp, pair.address)
+		if err != nil {
+			t.Errorf("Could not decode %s: %s", pair.address, err)
+			continue
+		}
+
+		script := newWitnessScript(version, program)
+		if script.String() != pair.script {
+			t.Errorf("Incorrect script for %s. Expected %s, got %s", pair.address, pair.script, script)
+		}
+
+		address, err := EncodeSegwitAddress(hrp, version, program)
+		if err != nil {
+			t.Errorf("Could not encode %s: %s", pair.script, err)
+		}
+		if address != strings.ToLower(pair.address) {
+			t.Errorf("Incorrect address for %s. Expected %s, got %s", pair.script, strings.ToLower(pair.address), address)
+		}
+	}
+}
+
+func TestSegwitAddressInvalid(t *testing.T) {
+	var tests = []struct {
+		address string
+		err     error
+	}{
+		// Bech32m checksum on a version 0 program, and bech32 on version 1
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", ErrInvalidWitnessProgram},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", ErrInvalidWitnessProgram},
+		// Version 0 program of 16 bytes, and programs of 41 and 1 bytes
+		{"bc1qw508d6qejxtdg4y5r3zarvaryvjsqfh9", ErrInvalidWitnessProgram},
+		{"bc1zqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqhkrh50", ErrInvalidWitnessProgram},
+		{"bc1zqqe86urf", ErrInvalidWitnessProgram},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", ErrInvalidChecksum},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3tb", ErrInvalidBech32},
+		{"bc1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", ErrInvalidBech32},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", ErrInvalidBech32},
+		{"bc1gmk9yu", ErrInvalidWitnessProgram},
+		{"bc1", ErrInvalidBech32},
+	}
+
+	for _, test := range tests {
+		_, _, err := DecodeSegwitAddress("bc", test.address)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Incorrect error for %s. Expected %s, got %v", test.address, test.err, err)
+		}
+	}
+}
+
+func TestBech32(t *testing.T) {
+	var tests = []struct {
+		encoded  string
+		encoding Bech32Encoding
+	}{
+		{"A12UEL5L", Bech32},
+		{"a12uel5l", Bech32},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", Bech32},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", Bech32},
+		{"A1LQFN3A", Bech32m},
+		{"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", Bech32m},
+		{"split1checkupstagehandshakeupstreamerranterredcaperredlc445v", Bech32m},
+	}
+
+	for _, test := range tests {
+		hrp, data, encoding, err := Bech32Decode(test.encoded)
+		if err != nil {
+			t.Errorf("Could not decode %s: %s", test.encoded, err)
+			continue
+		}
+		if encoding != test.encoding {
+			t.Errorf("Incorrect encoding for %s. Expected %d, got %d", test.encoded, test.encoding, encoding)
+		}
+
+		encoded, err := Bech32Encode(hrp, data, encoding)
+		if err != nil || encoded != strings.ToLower(test.encoded) {
+			t.Errorf("Incorrect round trip for %s. Got %s (%v)", test.encoded, encoded, err)
+		}
+	}
+}
+
+func TestScriptSegwitAddress(t *testing.T) {
+	var tests = []testpairaddress{
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", AddressParamsBitcoin, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"0020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d", AddressParamsBitcoin, "bc1qwqdg6squsna38e46795at95yu9atm8azzmyvckulcc7kytlcckxswvvzej"},
+		{"512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", AddressParamsBitcoin, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"51024e73", AddressParamsBitcoin, "bc1pfeessrawgf"},
+		{"5210751e76e8199196d454941c45d1b3a323", AddressParamsBitcoin, "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", AddressParamsLitecoin, "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", AddressParamsDigiByte, "dgb1qw508d6qejxtdg4y5r3zarvary0c5xw7kmudfnm"},
+	}
+
+	for _, pair := range tests {
+		script, _ := hex.DecodeString(pair.script)
+		address, err := Script(script).Address(&pair.params)
+		if err != nil {
+			t.Errorf("Could not get address for %s: %s", pair.script, err)
+		}
+		if address != pair.address {
+			t.Errorf("Incorrect address for %s. Expected %s, got %s", pair.script, pair.address, address)
+		}
+
+		decoded, err := AddressToScript(pair.address, &pair.params)
+		if err != nil {
+			t.Errorf("Could not decode %s: %s", pair.address, err)
+		}
+		if decoded.String() != pair.script {
+			t.Errorf("Incorrect script for %s. Expected %s, got %s", pair.address, pair.script, decoded)
+		}
+	}
+
+	// Dogecoin has no segwit addresses
+	script, _ := hex.DecodeString("0014751e76e8199196d454941c45d1b3a323f1433bd6")
+	if _, err := Script(script).Address(&AddressParamsDogecoin); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("Incorrect error for dogecoin witness script. Expected %s, got %v", ErrNoAddress, err)
+	}
+}
@@ -2,29 +2,163 @@ package blockutils
 
 import (
 	"encoding/hex"
+	"errors"
+	"fmt"
 )
 
+// Returned when a block has no transactions, or its coinbase has no inputs
+var ErrMissingCoinbase = errors.New("Block does not contain a coinbase transaction")
+
+// Returned for blocks using extensions to bitcoin's format which can't be
+// parsed, such as merge mined (AuxPoW) headers and Litecoin's MWEB data,
+// so that they can be skipped
+var ErrUnsupportedBlockFormat = errors.New("Unsupported block format")
+
+// Returned when a block's height can not be read from its coinbase
+var ErrHeightUnavailable = errors.New("Block height not available")
+
+// BIP34 heights are script numbers of at most 4 bytes
+const maxHeightLength = 4
+
 // Identical to bitcoin Script in transaction.go
 // Just for better readability
 type Hash256 []byte
 
-// Represents a single block in a blockchain.
-// blockutils does not validate transactions or blocks.
-// Height is only provided for blocks with version 2 or higher
-// and is 0 otherwise (be careful when dealing with that)
+// The length of a serialized block header
+const blockHeaderLength = 80
+
+// Represents the 80 byte header of a block, which is all that is needed
+// to follow the chain and its proof of work without the transactions.
 // Time is a unix timestamp
-type Block struct {
+type BlockHeader struct {
 	Version       uint32
 	PrevBlockHash Hash256
 	MerkleRoot    Hash256
-	Hash          Hash256
 	Time          uint32
 	NBits         uint32
 	Nonce         uint32
-	TxCount       uint64
-	Transactions  []*Transaction
-	Height        uint64
-	Coinbase      Script
+}
+
+// Represents a single block in a blockchain.
+// blockutils does not validate transactions or blocks.
+// Height is read from the coinbase as described in BIP34, and HasHeight
+// reports whether one was found. Blocks from before a chain activated BIP34
+// may still have a version of 2 or more and start their coinbase with a
+// number that isn't their height, so for a height that can be trusted parse
+// the block for its chain, such as with NewBlockFromBytesForChain, or use
+// HeightForChain
+//
+// Hash is calculated from the header when the block is parsed, as are
+// Size, StrippedSize and Weight, which are defined as for transactions
+type Block struct {
+	BlockHeader
+	Hash         Hash256
+	TxCount      uint64
+	Transactions []*Transaction
+	Height       uint64
+	HasHeight    bool
+	Coinbase     Script
+	Size         uint64
+	StrippedSize uint64
+	Weight       uint64
+}
+
+// Parses an 80 byte block header, such as from getblockheader or the
+// start of a serialized block
+func ParseBlockHeader(headerbytes []byte) (*BlockHeader, error) {
+	if len(headerbytes) != blockHeaderLength {
+		return nil, fmt.Errorf("Invalid block header length: expected %d bytes, got %d", blockHeaderLength, len(headerbytes))
+	}
+
+	headerreader := ByteReader{
+		Bytes:  headerbytes,
+		Cursor: 0,
+	}
+
+	return readBlockHeader(&headerreader)
+}
+
+// Returns a block header parsed from the given hexstring (such as from
+// `getblockheader <hash> false`)
+func NewBlockHeaderFromHexString(hexstring string) (*BlockHeader, error) {
+	headerbytes, err := hex.DecodeString(hexstring)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBlockHeader(headerbytes)
+}
+
+// Parses a series of concatenated block headers, such as from the binary
+// format of the /rest/headers/ endpoint
+func ParseBlockHeaders(headersbytes []byte) ([]*BlockHeader, error) {
+	if len(headersbytes)%blockHeaderLength != 0 {
+		return nil, fmt.Errorf("Invalid block headers length: %d bytes is not a multiple of %d", len(headersbytes), blockHeaderLength)
+	}
+
+	headersreader := ByteReader{
+		Bytes:  headersbytes,
+		Cursor: 0,
+	}
+
+	headers := make([]*BlockHeader, 0, len(headersbytes)/blockHeaderLength)
+	for headersreader.Remaining() > 0 {
+		header, err := readBlockHeader(&headersreader)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read block header %d: %w", len(headers), err)
+		}
+		headers = append(headers, header)
+	}
+
+	return headers, nil
+}
+
+func readBlockHeader(r byteSource) (*BlockHeader, error) {
+	version := r.ReadUint32()     // The first 4 bytes of a block are the version and signal bits
+	prevhash := r.ReadBytes(32)   // ... followed by the block hash of the previous block
+	merkleroot := r.ReadBytes(32) // ... followed by the tx merkle root
+	blockTime := r.ReadUint32()   // ... followed by the unix mining time
+	blockbits := r.ReadUint32()   // ... followed by the nbits
+	nonce := r.ReadUint32()       // ... followed by the nonce. This terminates the block header
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	return &BlockHeader{
+		Version:       version,
+		PrevBlockHash: prevhash,
+		MerkleRoot:    merkleroot,
+		Time:          blockTime,
+		NBits:         blockbits,
+		Nonce:         nonce,
+	}, nil
+}
+
+// Returns the header in its 80 byte wire format
+func (header *BlockHeader) Serialize() []byte {
+	writer := ByteWriter{}
+	header.writeTo(&writer)
+	return writer.Bytes
+}
+
+func (header *BlockHeader) writeTo(w *ByteWriter) {
+	w.WriteUint32(header.Version)
+	w.WriteBytes(header.PrevBlockHash)
+	w.WriteBytes(header.MerkleRoot)
+	w.WriteUint32(header.Time)
+	w.WriteUint32(header.NBits)
+	w.WriteUint32(header.Nonce)
+}
+
+// Calculates the block hash. Since the block header contains the tx
+// merkleroot, hashing the header automatically includes all the
+// transactions
+func (header *BlockHeader) Hash() Hash256 {
+	return DoubleSha256(header.Serialize())
+}
+
+func (header *BlockHeader) IsGenesisBlock() bool {
+	return AllZero(header.PrevBlockHash)
 }
 
 // Returns a block parsed from the given hexstring (such as
@@ -41,69 +175,178 @@ func NewBlockFromHexString(hexstring string) (*Block, error) {
 // Returns a block parsed from the given bytes (such as
 // from reading a blockchain file)
 func NewBlockFromBytes(blockbytes []byte) (*Block, error) {
-	var err error
 	blockreader := ByteReader{
 		Bytes:  blockbytes,
 		Cursor: 0,
 	}
 
+	return readBlock(&blockreader, func() (*Transaction, error) {
+		return ReadTransactionFromReader(&blockreader)
+	})
+}
+
+// Returns a block parsed from the given hexstring, with its height set if
+// BIP34 was active for it on the given chain
+func NewBlockFromHexStringForChain(hexstring string, params *ChainParams) (*Block, error) {
+	blockbytes, err := hex.DecodeString(hexstring)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBlockFromBytesForChain(blockbytes, params)
+}
+
+// Returns a block parsed from the given bytes, with its height set if
+// BIP34 was active for it on the given chain
+func NewBlockFromBytesForChain(blockbytes []byte, params *ChainParams) (*Block, error) {
+	block, err := NewBlockFromBytes(blockbytes)
+	if err != nil {
+		return nil, err
+	}
+
+	block.setChainHeight(params)
+	return block, nil
+}
+
+// Parses a block from r, using readTx to parse each of its transactions
+// from the same underlying data
+func readBlock(r byteSource, readTx func() (*Transaction, error)) (*Block, error) {
 	// The block header is the first 80 bytes of a block
-	// We peek at it since we still need to read it for its
-	// information later on
-	blockheader := blockreader.PeekBytes(80)
-
-	// Since the block header contains the tx merkleroot, hashing the
-	// header gives the block hash and automatically includes all the
-	// transactions
-	hash := DoubleSha256(blockheader)
-	version := blockreader.ReadUint32()          // The first 4 bytes of a block are the version and signal bits
-	prevhash := blockreader.ReadBytes(32)        // ... followed by the block hash of the previous block
-	merkleroot := blockreader.ReadBytes(32)      // ... followed by the tx merkle root
-	blockTime := blockreader.ReadUint32()        // ... followed by the unix mining time
-	blockbits := blockreader.ReadUint32()        // ... followed by the nbits
-	nonce := blockreader.ReadUint32()            // ... followed by the nonce. This terminates the block header
-	txcount := blockreader.ReadCompactSizeUint() // We then have the number of transactions in the blocks
-
-	txs := make([]*Transaction, txcount)
+	header, err := readBlockHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read block header: %w", err)
+	}
+
+	txcount := r.readCount("tx count") // We then have the number of transactions in the blocks
+	if r.Err() != nil {
+		return nil, fmt.Errorf("Could not read block tx count: %w", r.Err())
+	}
+
+	txs := make([]*Transaction, 0, preallocSize(txcount))
 	i := uint64(0)
 	for i < txcount {
-		tx, err := ReadTransactionFromReader(&blockreader) // ... followed by the actual raw transactions
+		tx, err := readTx() // ... followed by the actual raw transactions
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("Could not read block tx %d: %w", i, err)
 		}
 
-		txs[i] = tx
+		txs = append(txs, tx)
 		i += 1
 	}
+	if txcount == 0 || len(txs[0].Vin) == 0 {
+		return nil, ErrMissingCoinbase
+	}
+
+	size := uint64(blockHeaderLength + compactSizeUintLength(txcount))
+	strippedsize := size
+	for _, tx := range txs {
+		size += tx.Size
+		strippedsize += tx.StrippedSize
+	}
+
 	blockNumber := uint64(0)
-	if version >= 2 { // The block number is only defined in the coinbase tx if v>=2
-		coinbaseReader := ByteReader{
-			Bytes:  txs[0].Vin[0].Script,
-			Cursor: 0,
+	hasHeight := false
+	if header.Version >= 2 { // The block number is only defined in the coinbase tx if v>=2
+		height, err := DecodeCoinbaseHeight(txs[0].Vin[0].Script)
+		if err == nil {
+			blockNumber = height
+			hasHeight = true
 		}
-
-		blockNumberLength := uint64(coinbaseReader.ReadByte())          // The first byte specifies the length of the block number
-		blockHeightBytes := coinbaseReader.ReadBytes(blockNumberLength) // Read the actual block number bytes
-		blockNumber = bytesToUInt64(blockHeightBytes)                   // Convert to uint64
 	}
 
 	block := &Block{
-		Version:       version,
-		Hash:          hash,
-		PrevBlockHash: prevhash,
-		MerkleRoot:    merkleroot,
-		Time:          blockTime,
-		NBits:         blockbits,
-		Nonce:         nonce,
-		TxCount:       txcount,
-		Transactions:  txs,
-		Height:        blockNumber,
-		Coinbase:      txs[0].Vin[0].Script,
+		BlockHeader:  *header,
+		Hash:         header.Hash(),
+		TxCount:      txcount,
+		Transactions: txs,
+		Height:       blockNumber,
+		HasHeight:    hasHeight,
+		Coinbase:     txs[0].Vin[0].Script,
+		Size:         size,
+		StrippedSize: strippedsize,
+		Weight:       calcWeight(strippedsize, size),
 	}
 
-	return block, err
+	return block, nil
+}
+
+// Decodes the block height BIP34 requires at the start of a coinbase
+// scriptSig. It must be pushed as a minimally encoded, non negative script
+// number, as bitcoin's "CScript() << height" does, so heights up to 16 use
+// OP_0 to OP_16. Returns ErrHeightUnavailable otherwise
+func DecodeCoinbaseHeight(coinbase Script) (uint64, error) {
+	tokenizer := NewScriptTokenizer(coinbase)
+	if !tokenizer.Next() {
+		return 0, fmt.Errorf("Empty or truncated coinbase: %w", ErrHeightUnavailable)
+	}
+
+	height, err := tokenizer.Op().ScriptNum(maxHeightLength, true)
+	if err != nil {
+		return 0, fmt.Errorf("Coinbase does not start with a script number (%s): %w", err, ErrHeightUnavailable)
+	}
+	if height < 0 {
+		return 0, fmt.Errorf("Negative height %d: %w", height, ErrHeightUnavailable)
+	}
+	return uint64(height), nil
 }
 
-func (block *Block) IsGenesisBlock() bool {
-	return AllZero(block.PrevBlockHash)
+// Returns the block's height, as read from its coinbase, if BIP34 was
+// active for it on the given chain. Returns ErrHeightUnavailable if the
+// chain never activated BIP34, or the block is from before it did
+func (block *Block) HeightForChain(params *ChainParams) (uint64, error) {
+	if params.BIP34Height < 0 || block.Version < 2 {
+		return 0, fmt.Errorf("BIP34 not active for block %s on %s: %w", block.Hash, params.Name, ErrHeightUnavailable)
+	}
+	if len(block.Transactions) == 0 || len(block.Transactions[0].Vin) == 0 {
+		return 0, ErrMissingCoinbase
+	}
+
+	height, err := DecodeCoinbaseHeight(block.Transactions[0].Vin[0].Script)
+	if err != nil {
+		return 0, err
+	}
+
+	// Coinbases from before activation may start with anything, so only
+	// heights BIP34 applied to can be trusted. One which happens to start
+	// with a larger number can't be told apart without the block index
+	if height < uint64(params.BIP34Height) {
+		return 0, fmt.Errorf("Height %d is before BIP34 activation at %d on %s: %w", height, params.BIP34Height, params.Name, ErrHeightUnavailable)
+	}
+	return height, nil
+}
+
+// Sets Height and HasHeight from the coinbase if BIP34 was active for the
+// block on the given chain
+func (block *Block) setChainHeight(params *ChainParams) {
+	height, err := block.HeightForChain(params)
+	block.Height = height
+	block.HasHeight = err == nil
+}
+
+// Returns the block in its wire format, including witness data
+func (block *Block) Serialize() []byte {
+	writer := ByteWriter{}
+	block.BlockHeader.writeTo(&writer)
+	writer.WriteCompactSizeUint(uint64(len(block.Transactions)))
+	for _, tx := range block.Transactions {
+		tx.writeTo(&writer, true)
+	}
+	return writer.Bytes
+}
+
+// Implements encoding.BinaryMarshaler
+func (block *Block) MarshalBinary() ([]byte, error) {
+	return block.Serialize(), nil
+}
+
+// Implements encoding.BinaryUnmarshaler, replacing block with the
+// block parsed from data
+func (block *Block) UnmarshalBinary(data []byte) error {
+	parsed, err := NewBlockFromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	*block = *parsed
+	return nil
 }
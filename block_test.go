@@ -1,7 +1,10 @@
 package blockutils
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -126,3 +129,202 @@ func ExampleNewBlockFromHexString() {
 	// 		Value: 5000000000
 	// 		Script: 41045e071dedd1ed03721c6e9bba28fc276795421a378637fb41090192bb9f208630dcbac5862a3baeb9df3ca6e4e256b7fd2404824c20198ca1b004ee2197866433ac
 }
+
+func TestTruncatedBlock(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+
+	for _, length := range []int{0, 79, 80, 81, 300, len(blockbytes) - 1} {
+		_, err := NewBlockFromBytes(blockbytes[:length])
+		if !errors.Is(err, ErrUnexpectedEOF) {
+			t.Errorf("Expected ErrUnexpectedEOF for block truncated to %d bytes, got %v", length, err)
+		}
+
+		var readErr *ReadError
+		if err != nil && !errors.As(err, &readErr) {
+			t.Errorf("Expected ReadError for block truncated to %d bytes, got %v", length, err)
+		}
+	}
+}
+
+func TestBlockSerializeRoundTrip(t *testing.T) {
+	block, err := NewBlockFromHexString(dgb6257234)
+	if err != nil {
+		t.Fatalf("Could not parse block hex; %s", err)
+	}
+
+	if ToHexString(block.Serialize()) != dgb6257234 {
+		t.Errorf("Serialized block did not match. Expected %s, got %s", dgb6257234, ToHexString(block.Serialize()))
+	}
+
+	data, _ := block.MarshalBinary()
+	var decoded Block
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Could not unmarshal block; %s", err)
+	}
+
+	if decoded.Hash.String() != block.Hash.String() {
+		t.Errorf("Unmarshaled block hash did not match. Expected %s, got %s", block.Hash, decoded.Hash)
+	}
+}
+
+func TestParseBlockHeader(t *testing.T) {
+	header, err := NewBlockHeaderFromHexString(dgb6257234[:160])
+	if err != nil {
+		t.Fatalf("Could not parse block header; %s", err)
+	}
+
+	if header.Hash().String() != "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11" {
+		t.Errorf("Incorrect block hash. Expected %s, got %s", "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11", header.Hash())
+	}
+
+	if header.Nonce != 4078213543 {
+		t.Errorf("Incorrect nonce. Expected %d, got %d", 4078213543, header.Nonce)
+	}
+
+	if ToHexString(header.Serialize()) != dgb6257234[:160] {
+		t.Errorf("Serialized header did not match. Expected %s, got %s", dgb6257234[:160], ToHexString(header.Serialize()))
+	}
+
+	if _, err := NewBlockHeaderFromHexString(dgb6257234[:158]); err == nil {
+		t.Error("Expected error parsing short block header")
+	}
+}
+
+func TestParseBlockHeaders(t *testing.T) {
+	btc200header := "01000000eb68047fb29d78480b567ef6b76be556a2ec975656424508cc1c69b700000000bad58718fc3c6f5474918f06c44400c70b4c86d55a3f3ca3493b1d40c2061f2ba00f6b49ffff001d064b3a6d"
+	headersbytes, _ := hex.DecodeString(dgb6257234[:160] + btc200header)
+
+	headers, err := ParseBlockHeaders(headersbytes)
+	if err != nil {
+		t.Fatalf("Could not parse block headers; %s", err)
+	}
+
+	expected := []string{"7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11", "000000008f1a7008320c16b8402b7f11e82951f44ca2663caf6860ab2eeef320"}
+	if len(headers) != len(expected) {
+		t.Fatalf("Incorrect header count. Expected %d, got %d", len(expected), len(headers))
+	}
+
+	for i, header := range headers {
+		if header.Hash().String() != expected[i] {
+			t.Errorf("Incorrect hash for header %d. Expected %s, got %s", i, expected[i], header.Hash())
+		}
+	}
+
+	if _, err := ParseBlockHeaders(headersbytes[:100]); err == nil {
+		t.Error("Expected error parsing partial block headers")
+	}
+}
+
+func TestBlockWeight(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	size := uint64(len(dgb6257234) / 2)
+	strippedsize := size - 36 // The coinbase has a segwit marker, flag and a single 32 byte witness item
+	if block.Size != size || block.StrippedSize != strippedsize || block.Weight != strippedsize*3+size {
+		t.Errorf("Incorrect block sizes. Expected size %d, stripped %d, weight %d; got %d, %d, %d", size, strippedsize, strippedsize*3+size, block.Size, block.StrippedSize, block.Weight)
+	}
+
+	streamed, _ := DecodeBlock(hex.NewDecoder(strings.NewReader(dgb6257234)))
+	if streamed.Weight != block.Weight || streamed.StrippedSize != block.StrippedSize {
+		t.Errorf("Streamed block sizes did not match. Expected stripped %d, weight %d; got %d, %d", block.StrippedSize, block.Weight, streamed.StrippedSize, streamed.Weight)
+	}
+}
+
+func TestDecodeCoinbaseHeight(t *testing.T) {
+	var tests = []testpairuint64{
+		{[]byte{0x00}, 0},
+		{[]byte{0x51}, 1},
+		{[]byte{0x60, 0xff}, 16},
+		{[]byte{0x01, 0x11}, 17},
+		{[]byte{0x01, 0x7f}, 127},
+		{[]byte{0x02, 0x80, 0x00}, 128},
+		{[]byte{0x03, 0x5b, 0x7a, 0x03}, 227931},
+		{[]byte{0x03, 0x52, 0x7a, 0x5f, 0x04}, 6257234},
+		{[]byte{0x04, 0x00, 0x00, 0x80, 0x00}, 8388608},
+		{[]byte{0x04, 0xff, 0xff, 0xff, 0x7f}, 2147483647},
+		{[]byte{0x04, 0xff, 0xff, 0x00, 0x1d, 0x01, 0x38}, 486604799}, // Early coinbases pushed nBits, which reads as a number
+	}
+
+	for _, pair := range tests {
+		height, err := DecodeCoinbaseHeight(pair.input)
+		if err != nil {
+			t.Errorf("Could not decode height from %x: %s", pair.input, err)
+		}
+		if height != pair.output {
+			t.Errorf("Incorrect height for %x. Expected %d, got %d", pair.input, pair.output, height)
+		}
+	}
+
+	var invalid = []string{
+		"",
+		"4f",           // OP_1NEGATE
+		"76",           // Not a push
+		"0101",         // 1 should be OP_1
+		"020100",       // Unnecessary zero byte
+		"4c0111",       // Not the smallest push opcode
+		"0181",         // Negative
+		"050000008000", // Too long
+		"03527a",       // Truncated push
+	}
+
+	for _, test := range invalid {
+		coinbase, _ := hex.DecodeString(test)
+		if _, err := DecodeCoinbaseHeight(coinbase); !errors.Is(err, ErrHeightUnavailable) {
+			t.Errorf("Incorrect error for %s. Expected %s, got %v", test, ErrHeightUnavailable, err)
+		}
+	}
+}
+
+func TestBlockHeightForChain(t *testing.T) {
+	block, err := NewBlockFromHexString(dgb6257234)
+	if err != nil {
+		t.Fatalf("Could not parse block hex; %s", err)
+	}
+
+	if !block.HasHeight {
+		t.Error("Block height not decoded")
+	}
+
+	height, err := block.HeightForChain(&DigiByteParams)
+	if err != nil || height != 6257234 {
+		t.Errorf("Incorrect block height. Expected %d, got %d (%v)", 6257234, height, err)
+	}
+
+	// The same coinbase can't be trusted for a chain where BIP34 was not
+	// yet active at that height, or was never activated
+	params := DigiByteParams
+	params.BIP34Height = 7000000
+	if _, err := block.HeightForChain(&params); !errors.Is(err, ErrHeightUnavailable) {
+		t.Errorf("Incorrect error before BIP34 activation. Expected %s, got %v", ErrHeightUnavailable, err)
+	}
+
+	params.BIP34Height = -1
+	if _, err := block.HeightForChain(&params); !errors.Is(err, ErrHeightUnavailable) {
+		t.Errorf("Incorrect error without BIP34. Expected %s, got %v", ErrHeightUnavailable, err)
+	}
+
+	block, err = NewBlockFromHexStringForChain(dgb6257234, &params)
+	if err != nil {
+		t.Fatalf("Could not parse block hex; %s", err)
+	}
+	if block.HasHeight || block.Height != 0 {
+		t.Errorf("Unexpected height %d for a chain without BIP34", block.Height)
+	}
+}
+
+func TestBlockHeightUnavailable(t *testing.T) {
+	btc200 := "01000000eb68047fb29d78480b567ef6b76be556a2ec975656424508cc1c69b700000000bad58718fc3c6f5474918f06c44400c70b4c86d55a3f3ca3493b1d40c2061f2ba00f6b49ffff001d064b3a6d0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0704ffff001d0138ffffffff0100f2052a010000004341045e071dedd1ed03721c6e9bba28fc276795421a378637fb41090192bb9f208630dcbac5862a3baeb9df3ca6e4e256b7fd2404824c20198ca1b004ee2197866433ac00000000"
+
+	block, err := NewBlockFromHexString(btc200)
+	if err != nil {
+		t.Fatalf("Could not parse block hex; %s", err)
+	}
+
+	if block.HasHeight || block.Height != 0 {
+		t.Errorf("Unexpected height %d for a version 1 block", block.Height)
+	}
+
+	if _, err := block.HeightForChain(&BitcoinParams); !errors.Is(err, ErrHeightUnavailable) {
+		t.Errorf("Incorrect error for a version 1 block. Expected %s, got %v", ErrHeightUnavailable, err)
+	}
+}
@@ -0,0 +1,360 @@
+package blockutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The 4 bytes which begin every message on a network, and every block
+// record in its blk*.dat files
+type NetworkMagic [4]byte
+
+var (
+	MagicBitcoin         = NetworkMagic{0xf9, 0xbe, 0xb4, 0xd9}
+	MagicBitcoinTestnet3 = NetworkMagic{0x0b, 0x11, 0x09, 0x07}
+	MagicBitcoinSignet   = NetworkMagic{0x0a, 0x03, 0xcf, 0x40}
+	MagicBitcoinRegtest  = NetworkMagic{0xfa, 0xbf, 0xb5, 0xda}
+	MagicLitecoin        = NetworkMagic{0xfb, 0xc0, 0xb6, 0xdb}
+	MagicDigiByte        = NetworkMagic{0xfa, 0xc3, 0xb6, 0xda}
+	MagicDogecoin        = NetworkMagic{0xc0, 0xc0, 0xc0, 0xc0}
+	MagicBitcoinCash     = NetworkMagic{0xe3, 0xe1, 0xf3, 0xe8}
+)
+
+// Each block in a blk*.dat file is preceded by the network magic and
+// the block size as a uint32
+const blockRecordHeaderLength = 8
+
+// How much preallocated zero padding is read at a time while looking for
+// the next block record
+const paddingScanLength = 4096
+
+// Since version 28, Bitcoin Core obfuscates blk*.dat and rev*.dat files by
+// XORing them with a random key stored in this file in the blocks directory.
+// The byte at position p of a file is XORed with key[p % len(key)]
+const xorKeyFileName = "xor.dat"
+
+const xorKeyLength = 8
+
+// Merge mined blocks set this version bit, and have an AuxPoW proof of
+// work from the parent chain between their header and transactions
+const auxPowVersionBit = 0x100
+
+// Returned when a block record does not start with the expected
+// network magic
+var ErrBadMagic = errors.New("Network magic does not match")
+
+// A block read from a blk*.dat file, along with where it was found.
+// Offset is the position of the serialized block within the file, after
+// its magic and size, matching the position stored in bitcoin's block index
+type FileBlock struct {
+	*Block
+	File   string
+	Offset int64
+	Size   uint32
+}
+
+// A BlockFileReader walks the block records of a Bitcoin Core style
+// blk*.dat file, parsing each block with NewBlockFromBytes.
+//
+// Obfuscated files are read by setting the key from the blocks directory's
+// xor.dat with SetXORKey, which OpenBlockFile does automatically.
+//
+// Readers for a known chain, from NewBlockFileReaderForChain or
+// OpenBlockFileForChain, set Params and give each block its height, as
+// NewBlockFromBytesForChain does.
+//
+// Merge mined (AuxPoW) blocks, such as Dogecoin's, carry extra data after
+// their header, and Litecoin's MWEB blocks carry extension data, neither
+// of which NewBlockFromBytes supports. Reading one returns an error
+// wrapping ErrUnsupportedBlockFormat.
+//
+// Next moves past any block which fails to parse, such as these or a
+// corrupt one, so the following blocks can still be read. Only errors in
+// the record framing itself, like a bad magic or truncated record, stop it
+type BlockFileReader struct {
+	Name   string
+	Magic  NetworkMagic
+	Params *ChainParams
+	file   io.ReaderAt
+	closer io.Closer
+	offset int64
+	key    []byte
+}
+
+// Returns a BlockFileReader for file, which is reported as name in any
+// blocks read from it
+func NewBlockFileReader(file io.ReaderAt, name string, magic NetworkMagic) *BlockFileReader {
+	return &BlockFileReader{
+		Name:  name,
+		Magic: magic,
+		file:  file,
+	}
+}
+
+// Returns a BlockFileReader for file holding blocks of the given chain,
+// expecting the chain's BlockFileMagic
+func NewBlockFileReaderForChain(file io.ReaderAt, name string, params *ChainParams) *BlockFileReader {
+	reader := NewBlockFileReader(file, name, params.BlockFileMagic)
+	reader.Params = params
+	return reader
+}
+
+// Opens the blk*.dat file at path for reading, applying the obfuscation
+// key from xor.dat in the same directory if there is one. The file should
+// be closed with Close once done
+func OpenBlockFile(path string, magic NetworkMagic) (*BlockFileReader, error) {
+	key, err := ReadXORKey(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := NewBlockFileReader(file, path, magic)
+	reader.closer = file
+	reader.SetXORKey(key)
+	return reader, nil
+}
+
+// Opens the blk*.dat file of the given chain at path for reading, as
+// OpenBlockFile does
+func OpenBlockFileForChain(path string, params *ChainParams) (*BlockFileReader, error) {
+	reader, err := OpenBlockFile(path, params.BlockFileMagic)
+	if err != nil {
+		return nil, err
+	}
+
+	reader.Params = params
+	return reader, nil
+}
+
+// Reads the obfuscation key from the xor.dat file in a bitcoin blocks
+// directory. Returns a nil key if there is no xor.dat, as is the case for
+// blocks written before Bitcoin Core 28
+func ReadXORKey(blocksDir string) ([]byte, error) {
+	key, err := os.ReadFile(filepath.Join(blocksDir, xorKeyFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != xorKeyLength {
+		return nil, fmt.Errorf("Invalid %s length: expected %d bytes, got %d", xorKeyFileName, xorKeyLength, len(key))
+	}
+	return key, nil
+}
+
+// Sets the key the file is obfuscated with. An empty or all zero key
+// leaves the file data unchanged
+func (r *BlockFileReader) SetXORKey(key []byte) {
+	if AllZero(key) {
+		r.key = nil
+		return
+	}
+	r.key = key
+}
+
+// Closes the underlying file, if it was opened by OpenBlockFile
+func (r *BlockFileReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Returns the position in the file of the next block record
+func (r *BlockFileReader) Offset() int64 {
+	return r.offset
+}
+
+// Reads and parses the next block in the file. Returns io.EOF once there
+// are no more blocks, including when the rest of the file is the zero
+// padding bitcoin preallocates files with.
+func (r *BlockFileReader) Next() (*FileBlock, error) {
+	if err := r.skipPadding(); err != nil {
+		return nil, err
+	}
+
+	offset := r.offset + blockRecordHeaderLength
+	blockbytes, err := r.readRecordAt(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The record's framing is intact, so blocks which can't be parsed are
+	// still skipped over
+	r.offset = offset + int64(len(blockbytes))
+	return r.parseBlock(blockbytes, offset)
+}
+
+// Reads and parses the block at offset, as found in bitcoin's block
+// index, without moving the reader
+func (r *BlockFileReader) ReadBlockAt(offset int64) (*FileBlock, error) {
+	blockbytes, err := r.readRecordAt(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.parseBlock(blockbytes, offset)
+}
+
+// Parses the block read from offset
+func (r *BlockFileReader) parseBlock(blockbytes []byte, offset int64) (*FileBlock, error) {
+	if r.isAuxPowBlock(blockbytes) {
+		return nil, fmt.Errorf("%s: merge mined block at offset %d: %w", r.Name, offset, ErrUnsupportedBlockFormat)
+	}
+
+	block, err := NewBlockFromBytes(blockbytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not parse block at offset %d: %w", r.Name, offset, err)
+	}
+	if r.Params != nil {
+		block.setChainHeight(r.Params)
+	}
+
+	return &FileBlock{
+		Block:  block,
+		File:   r.Name,
+		Offset: offset,
+		Size:   uint32(len(blockbytes)),
+	}, nil
+}
+
+// Returns true if blockbytes is a merge mined block. Only chains which
+// allow merge mining use the version bit for it
+func (r *BlockFileReader) isAuxPowBlock(blockbytes []byte) bool {
+	if r.Magic != MagicDogecoin || len(blockbytes) < 4 {
+		return false
+	}
+	return binary.LittleEndian.Uint32(blockbytes)&auxPowVersionBit != 0
+}
+
+// Reads the data of the record at offset, checking the magic and size
+// which precede it. trailer bytes following the data, which are not
+// included in the size, are read along with it
+func (r *BlockFileReader) readRecordAt(offset int64, trailer int64) ([]byte, error) {
+	if offset < blockRecordHeaderLength {
+		return nil, fmt.Errorf("%s: invalid record offset %d", r.Name, offset)
+	}
+
+	recordOffset := offset - blockRecordHeaderLength
+	header := make([]byte, blockRecordHeaderLength)
+	if err := r.readAt(header, recordOffset); err != nil {
+		return nil, fmt.Errorf("%s: could not read record at offset %d: %w", r.Name, recordOffset, err)
+	}
+
+	var magic NetworkMagic
+	copy(magic[:], header[0:4])
+	if magic != r.Magic {
+		return nil, fmt.Errorf("%s: found %x at offset %d: %w", r.Name, magic, recordOffset, ErrBadMagic)
+	}
+
+	size := binary.LittleEndian.Uint32(header[4:8])
+	if size > maxStreamSize {
+		return nil, fmt.Errorf("%s: record size %d at offset %d: %w", r.Name, size, offset, ErrSizeTooLarge)
+	}
+
+	data := make([]byte, int64(size)+trailer)
+	if err := r.readAt(data, offset); err != nil {
+		return nil, fmt.Errorf("%s: could not read %d byte record at offset %d: %w", r.Name, size, offset, err)
+	}
+	return data, nil
+}
+
+// Fills data from the file at offset, treating a short read as
+// ErrUnexpectedEOF, and removes any obfuscation
+func (r *BlockFileReader) readAt(data []byte, offset int64) error {
+	n, err := r.file.ReadAt(data, offset)
+	if n != len(data) {
+		if err == nil || err == io.EOF {
+			err = ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	r.deobfuscate(data, offset)
+	return nil
+}
+
+// XORs data read from offset with the obfuscation key. The key index is
+// based on the position in the file, so reads may start anywhere
+func (r *BlockFileReader) deobfuscate(data []byte, offset int64) {
+	if r.key == nil {
+		return
+	}
+
+	keyLength := int64(len(r.key))
+	for i := range data {
+		data[i] ^= r.key[(offset+int64(i))%keyLength]
+	}
+}
+
+// Returns true if data, read from offset, starts with the network magic
+func (r *BlockFileReader) hasMagic(data []byte, offset int64) bool {
+	if len(data) < len(r.Magic) {
+		return false
+	}
+
+	var magic NetworkMagic
+	copy(magic[:], data)
+	r.deobfuscate(magic[:], offset)
+	return magic == r.Magic
+}
+
+// Moves the reader past any zero padding, returning io.EOF if the file
+// ends before another block record starts.
+//
+// Preallocated space is zero filled without obfuscation, so padding is
+// found by looking at the raw file bytes. An obfuscated record may itself
+// start with a zero byte, so the magic is checked first.
+func (r *BlockFileReader) skipPadding() error {
+	buf := make([]byte, paddingScanLength)
+	for {
+		n, err := r.file.ReadAt(buf, r.offset)
+		if r.hasMagic(buf[:n], r.offset) {
+			return nil
+		}
+
+		i := 0
+		for i < n && buf[i] == 0x00 {
+			i += 1
+		}
+		r.offset += int64(i)
+
+		if i < n {
+			if i == 0 {
+				return nil // Not padding; ReadBlockAt reports the bad magic
+			}
+			continue
+		}
+
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Returns the paths of the blk*.dat files in a bitcoin blocks directory,
+// in the order they were written
+func BlockFilePaths(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "blk[0-9][0-9][0-9][0-9][0-9].dat"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
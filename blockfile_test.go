@@ -0,0 +1,265 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Builds the contents of a blk*.dat file holding the given blocks,
+// followed by padding zero bytes
+func buildBlockFile(magic NetworkMagic, blocks [][]byte, padding int) []byte {
+	var file []byte
+	for _, block := range blocks {
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(block)))
+		file = append(file, magic[:]...)
+		file = append(file, size...)
+		file = append(file, block...)
+	}
+	return append(file, make([]byte, padding)...)
+}
+
+func TestBlockFileReader(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+	file := buildBlockFile(MagicDigiByte, [][]byte{blockbytes, blockbytes}, 10000)
+
+	reader := NewBlockFileReaderForChain(bytes.NewReader(file), "blk00000.dat", &DigiByteParams)
+	expectedOffsets := []int64{8, int64(len(blockbytes)) + 16}
+	for i, expectedOffset := range expectedOffsets {
+		block, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Could not read block %d; %s", i, err)
+		}
+
+		if block.Offset != expectedOffset {
+			t.Errorf("Incorrect offset for block %d. Expected %d, got %d", i, expectedOffset, block.Offset)
+		}
+
+		if block.File != "blk00000.dat" {
+			t.Errorf("Incorrect file for block %d. Expected %s, got %s", i, "blk00000.dat", block.File)
+		}
+
+		if block.Hash.String() != "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11" {
+			t.Errorf("Incorrect hash for block %d. Expected %s, got %s", i, "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11", block.Hash)
+		}
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last block, got %v", err)
+	}
+
+	block, err := reader.ReadBlockAt(expectedOffsets[1])
+	if err != nil {
+		t.Fatalf("Could not read block at offset; %s", err)
+	}
+
+	if !block.HasHeight || block.Height != 6257234 {
+		t.Errorf("Incorrect block height. Expected %d, got %d", 6257234, block.Height)
+	}
+}
+
+func TestBlockFileReaderBadMagic(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+	file := buildBlockFile(MagicDigiByte, [][]byte{blockbytes}, 0)
+
+	reader := NewBlockFileReader(bytes.NewReader(file), "blk00000.dat", MagicBitcoin)
+	if _, err := reader.Next(); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("Expected ErrBadMagic, got %v", err)
+	}
+}
+
+func TestBlockFileReaderTruncated(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+	file := buildBlockFile(MagicDigiByte, [][]byte{blockbytes}, 0)
+
+	reader := NewBlockFileReader(bytes.NewReader(file[:len(file)-5]), "blk00000.dat", MagicDigiByte)
+	if _, err := reader.Next(); !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestOpenBlockFile(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blk00000.dat")
+	os.WriteFile(path, buildBlockFile(MagicDigiByte, [][]byte{blockbytes}, 100), 0644)
+	os.WriteFile(filepath.Join(dir, "rev00000.dat"), nil, 0644)
+
+	paths, err := BlockFilePaths(dir)
+	if err != nil || len(paths) != 1 || paths[0] != path {
+		t.Fatalf("Incorrect block file paths. Expected [%s], got %v (%v)", path, paths, err)
+	}
+
+	reader, err := OpenBlockFileForChain(path, &DigiByteParams)
+	if err != nil {
+		t.Fatalf("Could not open block file; %s", err)
+	}
+	defer reader.Close()
+
+	block, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Could not read block; %s", err)
+	}
+
+	if block.File != path {
+		t.Errorf("Incorrect file. Expected %s, got %s", path, block.File)
+	}
+
+	if block.Height != 6257234 {
+		t.Errorf("Incorrect block height. Expected %d, got %d", 6257234, block.Height)
+	}
+}
+
+// XORs data with key as bitcoin does when writing obfuscated files
+func obfuscate(data []byte, key []byte) []byte {
+	obfuscated := make([]byte, len(data))
+	for i := range data {
+		obfuscated[i] = data[i] ^ key[i%len(key)]
+	}
+	return obfuscated
+}
+
+func TestBlockFileReaderXOR(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+	// The first key byte matches the magic, so the record starts with a 0x00
+	key := []byte{0xfa, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde}
+	data := obfuscate(buildBlockFile(MagicDigiByte, [][]byte{blockbytes, blockbytes}, 0), key)
+	file := append(data, make([]byte, 5000)...) // Preallocated padding is not obfuscated
+
+	reader := NewBlockFileReader(bytes.NewReader(file), "blk00000.dat", MagicDigiByte)
+	if _, err := reader.Next(); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("Expected ErrBadMagic without the key, got %v", err)
+	}
+
+	reader = NewBlockFileReader(bytes.NewReader(file), "blk00000.dat", MagicDigiByte)
+	reader.SetXORKey(key)
+
+	count := 0
+	for {
+		block, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Could not read block %d; %s", count, err)
+		}
+
+		if block.Hash.String() != "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11" {
+			t.Errorf("Incorrect hash for block %d. Expected %s, got %s", count, "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11", block.Hash)
+		}
+		count += 1
+	}
+
+	if count != 2 {
+		t.Errorf("Incorrect block count. Expected %d, got %d", 2, count)
+	}
+
+	// The second block does not start at a multiple of the key length
+	block, err := reader.ReadBlockAt(int64(len(blockbytes)) + 16)
+	if err != nil {
+		t.Fatalf("Could not read second block; %s", err)
+	}
+
+	if block.Height != 6257234 {
+		t.Errorf("Incorrect block height. Expected %d, got %d", 6257234, block.Height)
+	}
+}
+
+func TestOpenBlockFileXOR(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+	key := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blk00000.dat")
+	os.WriteFile(path, obfuscate(buildBlockFile(MagicDigiByte, [][]byte{blockbytes}, 0), key), 0644)
+	os.WriteFile(filepath.Join(dir, "xor.dat"), key, 0644)
+
+	readKey, err := ReadXORKey(dir)
+	if err != nil || !bytes.Equal(readKey, key) {
+		t.Errorf("Incorrect key. Expected %x, got %x (%v)", key, readKey, err)
+	}
+
+	reader, err := OpenBlockFile(path, MagicDigiByte)
+	if err != nil {
+		t.Fatalf("Could not open block file; %s", err)
+	}
+	defer reader.Close()
+
+	if _, err := reader.Next(); err != nil {
+		t.Errorf("Could not read obfuscated block; %s", err)
+	}
+}
+
+func TestBlockFileReaderUnsupportedBlocks(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+
+	// A merge mined Dogecoin header, version 0x00620104, followed by the
+	// start of its AuxPoW data
+	auxpow := append([]byte{}, blockbytes...)
+	binary.LittleEndian.PutUint32(auxpow, 0x00620104)
+
+	file := buildBlockFile(MagicDogecoin, [][]byte{auxpow, blockbytes}, 0)
+	reader := NewBlockFileReader(bytes.NewReader(file), "blk00000.dat", MagicDogecoin)
+	if _, err := reader.Next(); !errors.Is(err, ErrUnsupportedBlockFormat) {
+		t.Errorf("Incorrect error for merge mined block. Expected %s, got %v", ErrUnsupportedBlockFormat, err)
+	}
+
+	block, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Could not read block after merge mined block; %s", err)
+	}
+	if block.Hash.String() != "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11" {
+		t.Errorf("Incorrect hash. Expected %s, got %s", "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11", block.Hash)
+	}
+
+	// The version bit is only merge mining on chains which allow it
+	reader = NewBlockFileReader(bytes.NewReader(buildBlockFile(MagicDigiByte, [][]byte{auxpow}, 0)), "blk00000.dat", MagicDigiByte)
+	if _, err := reader.Next(); errors.Is(err, ErrUnsupportedBlockFormat) {
+		t.Errorf("Unexpected %s without merge mining", ErrUnsupportedBlockFormat)
+	}
+
+	// A block whose second tx has the MWEB flag set
+	mweb := append([]byte{}, blockbytes...)
+	txstart := bytes.Index(mweb, mustDecodeHex("0100000002be92100b"))
+	mweb = append(mweb[:txstart+4], append([]byte{0x00, 0x09}, mweb[txstart+4:]...)...)
+
+	file = buildBlockFile(MagicLitecoin, [][]byte{mweb, blockbytes}, 0)
+	reader = NewBlockFileReader(bytes.NewReader(file), "blk00000.dat", MagicLitecoin)
+	if _, err := reader.Next(); !errors.Is(err, ErrUnsupportedBlockFormat) {
+		t.Errorf("Incorrect error for MWEB block. Expected %s, got %v", ErrUnsupportedBlockFormat, err)
+	}
+	if _, err := reader.Next(); err != nil {
+		t.Errorf("Could not read block after MWEB block; %s", err)
+	}
+}
+
+func TestBlockFileReaderCorruptBlock(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+
+	// A record whose block claims more transactions than it holds
+	corrupt := append([]byte{}, blockbytes[:blockHeaderLength]...)
+	corrupt = append(corrupt, 0x05, 0x01, 0x00)
+
+	file := buildBlockFile(MagicDigiByte, [][]byte{corrupt, blockbytes}, 0)
+	reader := NewBlockFileReader(bytes.NewReader(file), "blk00000.dat", MagicDigiByte)
+	if _, err := reader.Next(); err == nil {
+		t.Error("Expected an error for a corrupt block")
+	}
+
+	block, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Could not read block after corrupt block; %s", err)
+	}
+	if block.Hash.String() != "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11" {
+		t.Errorf("Incorrect hash. Expected %s, got %s", "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11", block.Hash)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Incorrect error at end of file. Expected %s, got %v", io.EOF, err)
+	}
+}
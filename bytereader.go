@@ -1,60 +1,136 @@
 package blockutils
 
 import (
-	"bytes"
 	"encoding/binary"
-	"log"
+	"errors"
+	"fmt"
 )
 
 const length_UINT16 = 2
 const length_UINT32 = 4
 const length_UINT64 = 8
 
+// Returned (wrapped in a ReadError) when a read runs past the end of the
+// underlying data, such as when parsing a truncated block or tx hex
+var ErrUnexpectedEOF = errors.New("Unexpected end of data")
+
+// ReadError describes a failed read, including the position of the reader
+// and a description of what was being read at the time
+type ReadError struct {
+	Offset uint64
+	Field  string
+	Err    error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("Could not read %s at offset %d: %s", e.Field, e.Offset, e.Err)
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// Up to this many elements are allocated up front when reading a list whose
+// length comes from the data itself; longer lists grow as they are read
+const maxPreallocCount = 1024
+
+// Implemented by ByteReader and StreamReader so that both in memory and
+// streaming parsing share the same field level decoding
+type byteSource interface {
+	ReadUint32() uint32
+	ReadUint64() uint64
+	ReadBytes(length uint64) []byte
+	ReadCompactSizeUint() uint64
+	readCount(field string) uint64
+	Err() error
+}
+
+// A ByteReader walks a byte array, decoding the little endian values used
+// throughout the blockchain serialization formats.
+//
+// Reads are bounds checked. The first read that would run past the end of
+// Bytes records a sticky *ReadError, retrievable via Err, and every read
+// after that returns zero values without moving the cursor. This allows a
+// whole structure to be read before checking for errors once.
 type ByteReader struct {
 	Bytes  []byte
 	Cursor uint64
+	err    error
+}
+
+// Returns the first error encountered by the reader, if any
+func (r *ByteReader) Err() error {
+	return r.err
+}
+
+// Returns the number of bytes left to read after the cursor
+func (r *ByteReader) Remaining() uint64 {
+	if r.Cursor >= uint64(len(r.Bytes)) {
+		return 0
+	}
+	return uint64(len(r.Bytes)) - r.Cursor
+}
+
+// Checks that length bytes are available from start, recording a sticky
+// error describing field if they are not
+func (r *ByteReader) require(start uint64, length uint64, field string) bool {
+	if r.err != nil {
+		return false
+	}
+
+	size := uint64(len(r.Bytes))
+	if start > size || length > size-start {
+		r.err = &ReadError{
+			Offset: start,
+			Field:  field,
+			Err:    ErrUnexpectedEOF,
+		}
+		return false
+	}
+
+	return true
 }
 
 func (r *ByteReader) ReadUint16() uint16 {
-	val := uint16(0)
-	buf := bytes.NewBuffer(r.Bytes[r.Cursor : r.Cursor+length_UINT16])
-	err := binary.Read(buf, binary.LittleEndian, &val)
-	if err != nil {
-		log.Fatalf("Decode failed: %s", err)
+	if !r.require(r.Cursor, length_UINT16, "uint16") {
+		return 0
 	}
+	val := binary.LittleEndian.Uint16(r.Bytes[r.Cursor:])
 	r.Cursor += length_UINT16
 	return val
 }
 
 func (r *ByteReader) ReadUint32() uint32 {
-	val := uint32(0)
-	buf := bytes.NewBuffer(r.Bytes[r.Cursor : r.Cursor+length_UINT32])
-	err := binary.Read(buf, binary.LittleEndian, &val)
-	if err != nil {
-		log.Fatalf("Decode failed: %s", err)
+	if !r.require(r.Cursor, length_UINT32, "uint32") {
+		return 0
 	}
+	val := binary.LittleEndian.Uint32(r.Bytes[r.Cursor:])
 	r.Cursor += length_UINT32
 	return val
 }
 
 func (r *ByteReader) ReadUint64() uint64 {
-	val := uint64(0)
-	buf := bytes.NewBuffer(r.Bytes[r.Cursor : r.Cursor+length_UINT64])
-	err := binary.Read(buf, binary.LittleEndian, &val)
-	if err != nil {
-		log.Fatalf("Decode failed: %s", err)
+	if !r.require(r.Cursor, length_UINT64, "uint64") {
+		return 0
 	}
+	val := binary.LittleEndian.Uint64(r.Bytes[r.Cursor:])
 	r.Cursor += length_UINT64
 	return val
 }
 
 func (r *ByteReader) ReadByte() byte {
+	if !r.require(r.Cursor, 1, "byte") {
+		return 0
+	}
 	byteVal := r.Bytes[r.Cursor]
 	r.Cursor += 1
 	return byteVal
 }
 
 func (r *ByteReader) ReadBytes(length uint64) []byte {
+	if !r.require(r.Cursor, length, fmt.Sprintf("%d bytes", length)) {
+		return nil
+	}
 	byteVals := r.Bytes[r.Cursor : r.Cursor+length]
 	r.Cursor += length
 	return byteVals
@@ -65,6 +141,9 @@ func (r *ByteReader) ReadBytes(length uint64) []byte {
 // segwit tx
 
 func (r *ByteReader) PeekBytesFrom(start uint64, length uint64) []byte {
+	if !r.require(start, length, fmt.Sprintf("%d bytes", length)) {
+		return nil
+	}
 	byteVals := r.Bytes[start : start+length]
 	return byteVals
 }
@@ -73,8 +152,7 @@ func (r *ByteReader) PeekBytesFrom(start uint64, length uint64) []byte {
 // lookahead on data, such as checking if a tx is a segwit tx
 
 func (r *ByteReader) PeekBytes(length uint64) []byte {
-	byteVals := r.Bytes[r.Cursor : r.Cursor+length]
-	return byteVals
+	return r.PeekBytesFrom(r.Cursor, length)
 }
 
 // A compact size uint is defined as follows in the original satoshi code
@@ -107,6 +185,75 @@ func (r *ByteReader) ReadCompactSizeUint() uint64 {
 	}
 }
 
+// Reads a VARINT as used by bitcoin's CVarInt, which is distinct from a
+// compact size uint. Used in the undo and chainstate databases.
+//
+// Each byte holds 7 bits of the value, most significant first, with the
+// high bit set on every byte but the last. To make each value's encoding
+// unique, one is subtracted from every byte with its high bit set, which
+// is undone here by adding one after each such byte
+func (r *ByteReader) ReadVarInt() uint64 {
+	start := r.Cursor
+	n := uint64(0)
+	for {
+		chData := r.ReadByte()
+		if r.err != nil {
+			return 0
+		}
+
+		if n > (^uint64(0) >> 7) {
+			r.err = &ReadError{
+				Offset: start,
+				Field:  "varint",
+				Err:    ErrSizeTooLarge,
+			}
+			return 0
+		}
+
+		n = (n << 7) | uint64(chData&0x7F)
+		if chData&0x80 == 0 {
+			return n
+		}
+
+		if n == ^uint64(0) {
+			r.err = &ReadError{
+				Offset: start,
+				Field:  "varint",
+				Err:    ErrSizeTooLarge,
+			}
+			return 0
+		}
+		n += 1
+	}
+}
+
+// Reads a compact size uint used as an element count, such as the number
+// of inputs in a tx. Each element takes up at least one byte, so a count
+// larger than the remaining data is recorded as an error instead of being
+// used to allocate a huge slice.
+func (r *ByteReader) readCount(field string) uint64 {
+	start := r.Cursor
+	count := r.ReadCompactSizeUint()
+	if r.err == nil && count > r.Remaining() {
+		r.err = &ReadError{
+			Offset: start,
+			Field:  field,
+			Err:    ErrUnexpectedEOF,
+		}
+		return 0
+	}
+	return count
+}
+
+// Returns the capacity to allocate for a list of count elements read from
+// untrusted data
+func preallocSize(count uint64) uint64 {
+	if count > maxPreallocCount {
+		return maxPreallocCount
+	}
+	return count
+}
+
 // For segwit transactions, the canonical sha256(sha256(txhex)) returns
 // an incorrect hash. The valid txid needs to be calculated from the tx
 // as encodied in the original tx format. This requires us to strip the segwit
@@ -115,8 +262,7 @@ func (r *ByteReader) ReadCompactSizeUint() uint64 {
 // locktime.
 func (r *ByteReader) stripSegwit(txstartpos uint64, outputendpos uint64, nlocktimepos uint64) []byte {
 	txlength := nlocktimepos - txstartpos + 4
-	dup := make([]byte, txlength)
-	dup = copyFromIndex(r.Bytes, txstartpos, txlength)
+	dup := copyFromIndex(r.Bytes, txstartpos, txlength)
 	outputendpos = outputendpos - txstartpos
 	txstartpos = 0
 	noLocktime := append(dup[txstartpos:txstartpos+4], dup[txstartpos+6:outputendpos]...)
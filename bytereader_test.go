@@ -1,6 +1,9 @@
 package blockutils
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 type testpairuint64 struct {
 	input  []byte
@@ -32,3 +35,93 @@ func TestReadCompactSizeUint(t *testing.T) {
 		}
 	}
 }
+
+func TestByteReaderTruncated(t *testing.T) {
+	reader := ByteReader{
+		Bytes:  []byte{0x01, 0x02, 0x03},
+		Cursor: 0,
+	}
+
+	if reader.ReadUint16() != 0x0201 {
+		t.Error("Incorrect uint16 read before end of data")
+	}
+
+	if reader.ReadUint32() != 0 {
+		t.Error("Expected zero value for read past end of data")
+	}
+
+	var readErr *ReadError
+	if !errors.As(reader.Err(), &readErr) {
+		t.Fatalf("Expected a ReadError, got %v", reader.Err())
+	}
+
+	if readErr.Offset != 2 || readErr.Field != "uint32" {
+		t.Errorf("Incorrect error position. Expected offset %d reading %s, got offset %d reading %s", 2, "uint32", readErr.Offset, readErr.Field)
+	}
+
+	if !errors.Is(reader.Err(), ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF, got %v", reader.Err())
+	}
+
+	// The error is sticky, even for reads which would otherwise fit
+	if reader.ReadByte() != 0 || reader.Cursor != 2 {
+		t.Error("Reader continued reading after an error")
+	}
+}
+
+func TestByteReaderHugeLength(t *testing.T) {
+	reader := ByteReader{
+		Bytes:  []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		Cursor: 0,
+	}
+
+	length := reader.ReadCompactSizeUint()
+	if reader.ReadBytes(length) != nil {
+		t.Error("Expected nil bytes for length past end of data")
+	}
+
+	if !errors.Is(reader.Err(), ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF, got %v", reader.Err())
+	}
+}
+
+func TestReadVarInt(t *testing.T) {
+	tests := []testpairuint64{
+		{[]byte{0x00}, 0},
+		{[]byte{0x7f}, 0x7f},
+		{[]byte{0x80, 0x00}, 0x80},
+		{[]byte{0xa3, 0x34}, 0x1234},
+		{[]byte{0x82, 0xfe, 0x7f}, 0xffff},
+		{[]byte{0xc7, 0xe7, 0x56}, 0x123456},
+		{[]byte{0x86, 0xff, 0xc7, 0xe7, 0x56}, 0x80123456},
+		{[]byte{0x8e, 0xfe, 0xfe, 0xfe, 0x7f}, 0xffffffff},
+		{[]byte{0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0x7f}, 0x7fffffffffffffff},
+		{[]byte{0x80, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0x7f}, 0xffffffffffffffff},
+	}
+
+	for _, pair := range tests {
+		reader := ByteReader{
+			Bytes:  pair.input,
+			Cursor: 0,
+		}
+
+		intvalue := reader.ReadVarInt()
+
+		if intvalue != pair.output || reader.Err() != nil {
+			t.Error(
+				"For", pair.input,
+				"expected", pair.output,
+				"got", intvalue, reader.Err(),
+			)
+		}
+	}
+
+	reader := ByteReader{
+		Bytes:  []byte{0x81, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0x7f},
+		Cursor: 0,
+	}
+	reader.ReadVarInt()
+	if !errors.Is(reader.Err(), ErrSizeTooLarge) {
+		t.Errorf("Expected ErrSizeTooLarge for overflowing varint, got %v", reader.Err())
+	}
+}
@@ -0,0 +1,94 @@
+package blockutils
+
+import (
+	"encoding/binary"
+)
+
+// A ByteWriter builds up data in the little endian serialization used
+// throughout the blockchain. It is the counterpart to ByteReader
+type ByteWriter struct {
+	Bytes []byte
+}
+
+func (w *ByteWriter) WriteUint16(val uint16) {
+	var buf [length_UINT16]byte
+	binary.LittleEndian.PutUint16(buf[:], val)
+	w.Bytes = append(w.Bytes, buf[:]...)
+}
+
+func (w *ByteWriter) WriteUint32(val uint32) {
+	var buf [length_UINT32]byte
+	binary.LittleEndian.PutUint32(buf[:], val)
+	w.Bytes = append(w.Bytes, buf[:]...)
+}
+
+func (w *ByteWriter) WriteUint64(val uint64) {
+	var buf [length_UINT64]byte
+	binary.LittleEndian.PutUint64(buf[:], val)
+	w.Bytes = append(w.Bytes, buf[:]...)
+}
+
+func (w *ByteWriter) WriteBytes(data []byte) {
+	w.Bytes = append(w.Bytes, data...)
+}
+
+// Writes a compact size uint, using the shortest encoding for val. See
+// ByteReader.ReadCompactSizeUint for the format
+func (w *ByteWriter) WriteCompactSizeUint(val uint64) {
+	switch {
+	case val < 0xFD:
+		w.Bytes = append(w.Bytes, byte(val))
+	case val <= 0xFFFF:
+		w.Bytes = append(w.Bytes, 0xFD)
+		w.WriteUint16(uint16(val))
+	case val <= 0xFFFFFFFF:
+		w.Bytes = append(w.Bytes, 0xFE)
+		w.WriteUint32(uint32(val))
+	default:
+		w.Bytes = append(w.Bytes, 0xFF)
+		w.WriteUint64(val)
+	}
+}
+
+// Returns the number of bytes val takes up as a compact size uint
+func compactSizeUintLength(val uint64) int {
+	switch {
+	case val < 0xFD:
+		return 1
+	case val <= 0xFFFF:
+		return 3
+	case val <= 0xFFFFFFFF:
+		return 5
+	default:
+		return 9
+	}
+}
+
+// Writes the length of data as a compact size uint, followed by data
+func (w *ByteWriter) WriteVarBytes(data []byte) {
+	w.WriteCompactSizeUint(uint64(len(data)))
+	w.WriteBytes(data)
+}
+
+// Writes a VARINT as used by bitcoin's CVarInt. See ByteReader.ReadVarInt
+// for the format
+func (w *ByteWriter) WriteVarInt(val uint64) {
+	var tmp [10]byte
+	length := 0
+	for {
+		tmp[length] = byte(val & 0x7F)
+		if length > 0 {
+			tmp[length] |= 0x80
+		}
+		if val <= 0x7F {
+			break
+		}
+		val = (val >> 7) - 1
+		length += 1
+	}
+
+	for length >= 0 {
+		w.Bytes = append(w.Bytes, tmp[length])
+		length -= 1
+	}
+}
@@ -0,0 +1,51 @@
+package blockutils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCompactSizeUint(t *testing.T) {
+	tests := []testpairuint64{
+		{[]byte{0x45}, 69},
+		{[]byte{0xfd, 0x03, 0x02}, 515},
+		{[]byte{0xfe, 0x35, 0x64, 0x54, 0xe3}, 3813958709},
+		{[]byte{0xff, 0x48, 0xfe, 0xad, 0x43, 0xec, 0xcc, 0x4d, 0x9a}, 11118768370167447112},
+	}
+
+	for _, pair := range tests {
+		writer := ByteWriter{}
+		writer.WriteCompactSizeUint(pair.output)
+
+		if !bytes.Equal(writer.Bytes, pair.input) {
+			t.Error(
+				"For", pair.output,
+				"expected", pair.input,
+				"got", writer.Bytes,
+			)
+		}
+	}
+}
+
+func TestWriteVarInt(t *testing.T) {
+	tests := []testpairuint64{
+		{[]byte{0x00}, 0},
+		{[]byte{0x80, 0x00}, 0x80},
+		{[]byte{0xa3, 0x34}, 0x1234},
+		{[]byte{0x86, 0xff, 0xc7, 0xe7, 0x56}, 0x80123456},
+		{[]byte{0x80, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0x7f}, 0xffffffffffffffff},
+	}
+
+	for _, pair := range tests {
+		writer := ByteWriter{}
+		writer.WriteVarInt(pair.output)
+
+		if !bytes.Equal(writer.Bytes, pair.input) {
+			t.Error(
+				"For", pair.output,
+				"expected", pair.input,
+				"got", writer.Bytes,
+			)
+		}
+	}
+}
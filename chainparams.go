@@ -0,0 +1,278 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// Bitcoin amounts are in satoshis, 1e8 to the coin
+const coin = 100000000
+
+// The parameters of a chain which affect how its data is parsed and
+// shown, as in bitcoin's chainparams.cpp.
+//
+// The subsidy of most chains halves every SubsidyHalvingInterval blocks,
+// starting at InitialSubsidy. Chains with other schedules set SubsidyFunc
+// instead, see Subsidy.
+//
+// Magic is the network magic used by the chain's p2p messages, and
+// BlockFileMagic the one used by its blk*.dat files. These only differ
+// for Bitcoin Cash, which kept bitcoin's magic for its block files.
+//
+// SegwitHeight and BIP34Height are the first blocks segwit and BIP34
+// coinbase heights were required for, or -1 if the chain never activated
+// them.
+//
+// Block parsing and block files take the whole params through their
+// ForChain variants, such as NewBlockFromBytesForChain and
+// OpenBlockFileForChain. Functions which only need part of them take that
+// part: &params.AddressParams for Script.Address and AddressToScript, and
+// params.PowLimit for CheckProofOfWork and Difficulty
+type ChainParams struct {
+	Name string
+	AddressParams
+	Magic                  NetworkMagic
+	BlockFileMagic         NetworkMagic
+	PowLimit               *big.Int
+	GenesisHash            Hash256
+	InitialSubsidy         uint64
+	SubsidyHalvingInterval int32
+	SubsidyFunc            func(height int32) (uint64, bool)
+	SegwitHeight           int32
+	BIP34Height            int32
+}
+
+// Returned when registering chain params under a name which is in use
+var ErrDuplicateChainParams = errors.New("Chain params already registered")
+
+// Returned when there are no chain params with the requested name or magic
+var ErrUnknownChain = errors.New("Unknown chain")
+
+var (
+	BitcoinParams = ChainParams{
+		Name:                   "bitcoin",
+		AddressParams:          AddressParamsBitcoin,
+		Magic:                  MagicBitcoin,
+		BlockFileMagic:         MagicBitcoin,
+		PowLimit:               PowLimitBitcoin,
+		GenesisHash:            hashFromHex("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"),
+		InitialSubsidy:         50 * coin,
+		SubsidyHalvingInterval: 210000,
+		SegwitHeight:           481824,
+		BIP34Height:            227931,
+	}
+
+	BitcoinTestnet3Params = ChainParams{
+		Name:                   "bitcoin-testnet3",
+		AddressParams:          AddressParamsBitcoinTestnet,
+		Magic:                  MagicBitcoinTestnet3,
+		BlockFileMagic:         MagicBitcoinTestnet3,
+		PowLimit:               PowLimitBitcoin,
+		GenesisHash:            hashFromHex("000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"),
+		InitialSubsidy:         50 * coin,
+		SubsidyHalvingInterval: 210000,
+		SegwitHeight:           834624,
+		BIP34Height:            21111,
+	}
+
+	BitcoinSignetParams = ChainParams{
+		Name:                   "bitcoin-signet",
+		AddressParams:          AddressParamsBitcoinTestnet,
+		Magic:                  MagicBitcoinSignet,
+		BlockFileMagic:         MagicBitcoinSignet,
+		PowLimit:               PowLimitBitcoinSignet,
+		GenesisHash:            hashFromHex("00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"),
+		InitialSubsidy:         50 * coin,
+		SubsidyHalvingInterval: 210000,
+		SegwitHeight:           1,
+		BIP34Height:            1,
+	}
+
+	BitcoinRegtestParams = ChainParams{
+		Name:                   "bitcoin-regtest",
+		AddressParams:          AddressParamsBitcoinRegtest,
+		Magic:                  MagicBitcoinRegtest,
+		BlockFileMagic:         MagicBitcoinRegtest,
+		PowLimit:               PowLimitBitcoinRegtest,
+		GenesisHash:            hashFromHex("0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"),
+		InitialSubsidy:         50 * coin,
+		SubsidyHalvingInterval: 150,
+		SegwitHeight:           0,
+		BIP34Height:            1,
+	}
+
+	LitecoinParams = ChainParams{
+		Name:                   "litecoin",
+		AddressParams:          AddressParamsLitecoin,
+		Magic:                  MagicLitecoin,
+		BlockFileMagic:         MagicLitecoin,
+		PowLimit:               PowLimitLitecoin,
+		GenesisHash:            hashFromHex("12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2"),
+		InitialSubsidy:         50 * coin,
+		SubsidyHalvingInterval: 840000,
+		SegwitHeight:           1201536,
+		BIP34Height:            710000,
+	}
+
+	DogecoinParams = ChainParams{
+		Name:           "dogecoin",
+		AddressParams:  AddressParamsDogecoin,
+		Magic:          MagicDogecoin,
+		BlockFileMagic: MagicDogecoin,
+		PowLimit:       PowLimitDogecoin,
+		GenesisHash:    hashFromHex("1a91e3dace36e2be3bf030a65679fe821aa1d6ef92e7c9902eb318182c355691"),
+		SubsidyFunc:    dogecoinSubsidy,
+		SegwitHeight:   -1,
+		BIP34Height:    1034383,
+	}
+
+	// DigiByte's subsidy is reduced in many steps, which Subsidy does not
+	// implement
+	DigiByteParams = ChainParams{
+		Name:           "digibyte",
+		AddressParams:  AddressParamsDigiByte,
+		Magic:          MagicDigiByte,
+		BlockFileMagic: MagicDigiByte,
+		PowLimit:       PowLimitDigiByte,
+		GenesisHash:    hashFromHex("7497ea1b465eb39f1c8f507bc877078fe016d6fcb6dfad3a64c98dcc6e1e8496"),
+		SegwitHeight:   4394880,
+		BIP34Height:    4394880,
+	}
+
+	// Bitcoin Cash shares bitcoin's history up to block 478558. Its
+	// cashaddr addresses are not supported, only legacy base58 ones
+	BitcoinCashParams = ChainParams{
+		Name:                   "bitcoincash",
+		AddressParams:          AddressParamsBitcoinCash,
+		Magic:                  MagicBitcoinCash,
+		BlockFileMagic:         MagicBitcoin,
+		PowLimit:               PowLimitBitcoin,
+		GenesisHash:            hashFromHex("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"),
+		InitialSubsidy:         50 * coin,
+		SubsidyHalvingInterval: 210000,
+		SegwitHeight:           -1,
+		BIP34Height:            227931,
+	}
+)
+
+// The registry may be added to while other goroutines look params up, so
+// it is only accessed with registryLock held. chainParamsOrder holds the
+// registered params in the order they were added
+var (
+	registryLock          sync.RWMutex
+	registeredChainParams = map[string]*ChainParams{}
+	chainParamsOrder      []*ChainParams
+)
+
+func init() {
+	for _, params := range []*ChainParams{
+		&BitcoinParams,
+		&BitcoinTestnet3Params,
+		&BitcoinSignetParams,
+		&BitcoinRegtestParams,
+		&LitecoinParams,
+		&DogecoinParams,
+		&DigiByteParams,
+		&BitcoinCashParams,
+	} {
+		if err := RegisterChainParams(params); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Adds params to the registry, making them available to ChainParamsByName
+// and ChainParamsByMagic
+func RegisterChainParams(params *ChainParams) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registeredChainParams[params.Name]; ok {
+		return fmt.Errorf("Could not register %s: %w", params.Name, ErrDuplicateChainParams)
+	}
+
+	registeredChainParams[params.Name] = params
+	chainParamsOrder = append(chainParamsOrder, params)
+	return nil
+}
+
+// Returns the registered chain params with the given name
+func ChainParamsByName(name string) (*ChainParams, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	params, ok := registeredChainParams[name]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	return params, nil
+}
+
+// Returns the registered chain params whose block files start with magic.
+// When several chains share a magic, the one registered first is
+// returned, so bitcoin and Bitcoin Cash block files give bitcoin
+func ChainParamsByMagic(magic NetworkMagic) (*ChainParams, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	for _, params := range chainParamsOrder {
+		if params.BlockFileMagic == magic {
+			return params, nil
+		}
+	}
+	return nil, ErrUnknownChain
+}
+
+// Returns the new coins a block at height may create, excluding fees, as
+// GetBlockSubsidy in bitcoin's validation.cpp. Returns false if the
+// subsidy at height can not be determined
+func (params *ChainParams) Subsidy(height int32) (uint64, bool) {
+	if height < 0 {
+		return 0, false
+	}
+	if params.SubsidyFunc != nil {
+		return params.SubsidyFunc(height)
+	}
+	if params.SubsidyHalvingInterval <= 0 {
+		return 0, false
+	}
+
+	halvings := height / params.SubsidyHalvingInterval
+	if halvings >= 64 {
+		return 0, true
+	}
+	return params.InitialSubsidy >> uint(halvings), true
+}
+
+// Returns true if segwit was active for the block at height
+func (params *ChainParams) SegwitActive(height int32) bool {
+	return params.SegwitHeight >= 0 && height >= params.SegwitHeight
+}
+
+// Returns true if hash is the chain's genesis block hash
+func (params *ChainParams) IsGenesisHash(hash Hash256) bool {
+	return bytes.Equal(hash, params.GenesisHash)
+}
+
+// Dogecoin's subsidy was random until block 145000. After that it is
+// fixed, halving every 100000 blocks until it reaches 10000 DOGE at
+// block 600000
+func dogecoinSubsidy(height int32) (uint64, bool) {
+	if height < 145000 {
+		return 0, false
+	}
+	if height < 600000 {
+		return (500000 * coin) >> uint(height/100000), true
+	}
+	return 10000 * coin, true
+}
+
+// Returns a hash from its hex form, shown in reverse byte order
+func hashFromHex(hexstring string) Hash256 {
+	hash, _ := hex.DecodeString(hexstring)
+	return ReverseHex(hash)
+}
@@ -0,0 +1,183 @@
+package blockutils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestChainParamsGenesis(t *testing.T) {
+	header, err := NewBlockHeaderFromHexString("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c")
+	if err != nil {
+		t.Fatalf("Could not parse header: %s", err)
+	}
+
+	if !BitcoinParams.IsGenesisHash(header.Hash()) {
+		t.Errorf("Incorrect genesis hash. Expected %s, got %s", BitcoinParams.GenesisHash, header.Hash())
+	}
+	if err := header.CheckProofOfWork(BitcoinParams.PowLimit); err != nil {
+		t.Errorf("Genesis block failed proof of work check: %s", err)
+	}
+
+	// Regtest shares the genesis block, with an easier target
+	header.Time = 1296688602
+	header.NBits = 0x207fffff
+	header.Nonce = 2
+	if !BitcoinRegtestParams.IsGenesisHash(header.Hash()) {
+		t.Errorf("Incorrect regtest genesis hash. Expected %s, got %s", BitcoinRegtestParams.GenesisHash, header.Hash())
+	}
+}
+
+// Registers params for the duration of a test, removing them from the
+// registry again once it is done so that tests can be repeated
+func registerTestChainParams(t *testing.T, params *ChainParams) {
+	t.Helper()
+	if err := RegisterChainParams(params); err != nil {
+		t.Fatalf("Could not register %s: %s", params.Name, err)
+	}
+	t.Cleanup(func() {
+		unregisterChainParams(params.Name)
+	})
+}
+
+func unregisterChainParams(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	delete(registeredChainParams, name)
+	for i, params := range chainParamsOrder {
+		if params.Name == name {
+			chainParamsOrder = append(chainParamsOrder[:i], chainParamsOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+func TestChainParamsRegistry(t *testing.T) {
+	for _, name := range []string{"bitcoin", "bitcoin-testnet3", "bitcoin-signet", "bitcoin-regtest", "litecoin", "dogecoin", "digibyte", "bitcoincash"} {
+		params, err := ChainParamsByName(name)
+		if err != nil {
+			t.Errorf("Could not find chain params for %s: %s", name, err)
+			continue
+		}
+		if params.Name != name {
+			t.Errorf("Incorrect chain params. Expected %s, got %s", name, params.Name)
+		}
+
+		byMagic, err := ChainParamsByMagic(params.BlockFileMagic)
+		if err != nil {
+			t.Errorf("Could not find chain params for magic %x: %s", params.BlockFileMagic, err)
+		} else if name != "bitcoincash" && byMagic != params {
+			t.Errorf("Incorrect chain params for magic %x. Expected %s, got %s", params.BlockFileMagic, name, byMagic.Name)
+		}
+	}
+
+	params, _ := ChainParamsByMagic(MagicBitcoin)
+	if params != &BitcoinParams {
+		t.Errorf("Incorrect chain params for bitcoin magic. Got %s", params.Name)
+	}
+
+	if _, err := ChainParamsByName("nocoin"); !errors.Is(err, ErrUnknownChain) {
+		t.Errorf("Incorrect error for unknown chain. Expected %s, got %v", ErrUnknownChain, err)
+	}
+	if _, err := ChainParamsByMagic(NetworkMagic{}); !errors.Is(err, ErrUnknownChain) {
+		t.Errorf("Incorrect error for unknown magic. Expected %s, got %v", ErrUnknownChain, err)
+	}
+
+	if err := RegisterChainParams(&ChainParams{Name: "bitcoin"}); !errors.Is(err, ErrDuplicateChainParams) {
+		t.Errorf("Incorrect error for duplicate chain. Expected %s, got %v", ErrDuplicateChainParams, err)
+	}
+
+	registerTestChainParams(t, &ChainParams{Name: "registrycoin"})
+	if err := RegisterChainParams(&ChainParams{Name: "registrycoin"}); !errors.Is(err, ErrDuplicateChainParams) {
+		t.Errorf("Incorrect error for duplicate chain. Expected %s, got %v", ErrDuplicateChainParams, err)
+	}
+}
+
+func TestChainParamsByMagicOrder(t *testing.T) {
+	magic := NetworkMagic{0xfd, 0xfd, 0xfd, 0xfd}
+	first := &ChainParams{Name: "sharedcoin-a", BlockFileMagic: magic}
+	registerTestChainParams(t, first)
+	for i := 0; i < 16; i++ {
+		registerTestChainParams(t, &ChainParams{Name: fmt.Sprintf("sharedcoin-%d", i), BlockFileMagic: magic})
+	}
+
+	for i := 0; i < 16; i++ {
+		params, err := ChainParamsByMagic(magic)
+		if err != nil || params != first {
+			t.Fatalf("Incorrect chain params for shared magic. Expected %s, got %v (%v)", first.Name, params, err)
+		}
+	}
+}
+
+func TestRegisterChainParamsConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("concurrentcoin-%d", i)
+		t.Cleanup(func() {
+			unregisterChainParams(name)
+		})
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := RegisterChainParams(&ChainParams{Name: name, BlockFileMagic: NetworkMagic{0xfe, 0xfe, 0xfe, byte(i)}}); err != nil {
+				t.Errorf("Could not register %s: %s", name, err)
+			}
+			ChainParamsByName("bitcoin")
+			ChainParamsByMagic(MagicLitecoin)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		if _, err := ChainParamsByName(fmt.Sprintf("concurrentcoin-%d", i)); err != nil {
+			t.Errorf("Could not find concurrently registered chain %d: %s", i, err)
+		}
+	}
+}
+
+func TestChainParamsSubsidy(t *testing.T) {
+	var tests = []struct {
+		params *ChainParams
+		height int32
+		output uint64
+		ok     bool
+	}{
+		{&BitcoinParams, 0, 5000000000, true},
+		{&BitcoinParams, 209999, 5000000000, true},
+		{&BitcoinParams, 210000, 2500000000, true},
+		{&BitcoinParams, 840000, 312500000, true},
+		{&BitcoinParams, 6720000, 1, true},
+		{&BitcoinParams, 6930000, 0, true},
+		{&BitcoinParams, 210000 * 64, 0, true},
+		{&BitcoinParams, -1, 0, false},
+		{&BitcoinRegtestParams, 150, 2500000000, true},
+		{&LitecoinParams, 840000, 2500000000, true},
+		{&DogecoinParams, 100000, 0, false},
+		{&DogecoinParams, 145000, 25000000000000, true},
+		{&DogecoinParams, 599999, 1562500000000, true},
+		{&DogecoinParams, 600000, 1000000000000, true},
+		{&DigiByteParams, 6257234, 0, false},
+	}
+
+	for _, test := range tests {
+		subsidy, ok := test.params.Subsidy(test.height)
+		if subsidy != test.output || ok != test.ok {
+			t.Errorf("Incorrect %s subsidy at %d. Expected %d %t, got %d %t", test.params.Name, test.height, test.output, test.ok, subsidy, ok)
+		}
+	}
+}
+
+func TestChainParamsSegwit(t *testing.T) {
+	if BitcoinParams.SegwitActive(481823) || !BitcoinParams.SegwitActive(481824) {
+		t.Error("Incorrect segwit activation for bitcoin")
+	}
+	if DogecoinParams.SegwitActive(5000000) || BitcoinCashParams.SegwitActive(5000000) {
+		t.Error("Segwit active on a chain without segwit")
+	}
+	if !BitcoinRegtestParams.SegwitActive(0) {
+		t.Error("Segwit not active from genesis on regtest")
+	}
+}
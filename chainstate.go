@@ -0,0 +1,187 @@
+package blockutils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Chainstate database keys are prefixed by a byte identifying the type of
+// entry, as defined in bitcoin's txdb.cpp
+const (
+	chainstateCoinPrefix      = 'C'
+	chainstateBestBlockPrefix = 'B'
+)
+
+// The key under which the chainstate stores the key its values are
+// obfuscated with
+var chainstateObfuscateKey = append([]byte{0x0e, 0x00}, "obfuscate_key"...)
+
+// Returned when the requested entry is not in the chainstate
+var ErrNotFound = errors.New("Not found in chainstate")
+
+// Returned when a chainstate key is not a coin key
+var ErrInvalidCoinKey = errors.New("Invalid coin key")
+
+// Read access to a chainstate database, such as a LevelDB handle on
+// bitcoin's chainstate directory. Get returns the value stored for key as
+// is, or a nil value and nil error if there is no such key
+type ChainstateDB interface {
+	Get(key []byte) ([]byte, error)
+}
+
+// A Chainstate decodes the unspent outputs stored in a bitcoin chainstate
+// database, removing the obfuscation applied to its values
+type Chainstate struct {
+	db  ChainstateDB
+	key []byte
+}
+
+// Returns a Chainstate reading from db, loading its obfuscation key
+func NewChainstate(db ChainstateDB) (*Chainstate, error) {
+	value, err := db.Get(chainstateObfuscateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	chainstate := &Chainstate{
+		db: db,
+	}
+
+	// Databases created before obfuscation was introduced have no key
+	if value != nil {
+		keyreader := ByteReader{
+			Bytes:  value,
+			Cursor: 0,
+		}
+		keylength := keyreader.readCount("obfuscation key length") // The key is stored as a vector, prefixed with its length
+		chainstate.key = keyreader.ReadBytes(keylength)
+		if keyreader.Err() != nil {
+			return nil, fmt.Errorf("Could not read obfuscation key: %w", keyreader.Err())
+		}
+	}
+
+	return chainstate, nil
+}
+
+// Returns the unspent output at index vout of the tx txid. Returns
+// ErrNotFound if there is no such unspent output
+func (c *Chainstate) Coin(txid Hash256, vout uint32) (*Coin, error) {
+	value, err := c.get(CoinKey(txid, vout))
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeCoin(value)
+}
+
+// Returns the hash of the block the chainstate is up to date with
+func (c *Chainstate) BestBlock() (Hash256, error) {
+	value, err := c.get([]byte{chainstateBestBlockPrefix})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(value) != 32 {
+		return nil, fmt.Errorf("Invalid best block length %d", len(value))
+	}
+	return value, nil
+}
+
+// Decodes a raw key and value pair of the database, such as while
+// iterating over it, into the outpoint and unspent output it describes
+func (c *Chainstate) DecodeEntry(key []byte, value []byte) (Hash256, uint32, *Coin, error) {
+	txid, vout, err := DecodeCoinKey(key)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	coin, err := DecodeCoin(c.Deobfuscate(value))
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	return txid, vout, coin, nil
+}
+
+// Returns a copy of value with the database's obfuscation removed
+func (c *Chainstate) Deobfuscate(value []byte) []byte {
+	plain := make([]byte, len(value))
+	copy(plain, value)
+	if len(c.key) == 0 {
+		return plain
+	}
+
+	for i := range plain {
+		plain[i] ^= c.key[i%len(c.key)]
+	}
+	return plain
+}
+
+func (c *Chainstate) get(key []byte) ([]byte, error) {
+	value, err := c.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrNotFound
+	}
+
+	return c.Deobfuscate(value), nil
+}
+
+// Returns the chainstate key of the output at index vout of the tx txid
+func CoinKey(txid Hash256, vout uint32) []byte {
+	writer := ByteWriter{}
+	writer.WriteBytes([]byte{chainstateCoinPrefix})
+	writer.WriteBytes(txid)
+	writer.WriteVarInt(uint64(vout))
+	return writer.Bytes
+}
+
+// Returns the txid and output index a chainstate coin key refers to
+func DecodeCoinKey(key []byte) (Hash256, uint32, error) {
+	keyreader := ByteReader{
+		Bytes:  key,
+		Cursor: 0,
+	}
+
+	prefix := keyreader.ReadByte()  // Coin keys are the 'C' prefix
+	txid := keyreader.ReadBytes(32) // ... followed by the txid
+	vout := keyreader.ReadVarInt()  // ... followed by the output index
+	if keyreader.Err() != nil {
+		return nil, 0, fmt.Errorf("Could not read coin key: %w", keyreader.Err())
+	}
+
+	if prefix != chainstateCoinPrefix || keyreader.Remaining() != 0 || vout > 0xFFFFFFFF {
+		return nil, 0, ErrInvalidCoinKey
+	}
+
+	return txid, uint32(vout), nil
+}
+
+// Decodes a deobfuscated chainstate coin value
+func DecodeCoin(value []byte) (*Coin, error) {
+	coinreader := ByteReader{
+		Bytes:  value,
+		Cursor: 0,
+	}
+
+	code := coinreader.ReadVarInt() // The height and coinbase flag are combined as height*2 + coinbase
+	if coinreader.Err() != nil {
+		return nil, fmt.Errorf("Could not read coin: %w", coinreader.Err())
+	}
+	if code>>1 > 0xFFFFFFFF {
+		return nil, fmt.Errorf("Could not read coin: %w", &ReadError{Offset: 0, Field: "coin height", Err: ErrSizeTooLarge})
+	}
+
+	txout, err := readCompressedTxOutput(&coinreader) // ... followed by the compressed output
+	if err != nil {
+		return nil, fmt.Errorf("Could not read coin: %w", err)
+	}
+
+	return &Coin{
+		TxOutput:   txout,
+		Height:     uint32(code >> 1),
+		IsCoinbase: code&0x01 == 0x01,
+	}, nil
+}
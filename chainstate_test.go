@@ -0,0 +1,154 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// An in memory ChainstateDB
+type mapChainstateDB map[string][]byte
+
+func (db mapChainstateDB) Get(key []byte) ([]byte, error) {
+	return db[string(key)], nil
+}
+
+func TestChainstateCoin(t *testing.T) {
+	obfuscationKey := []byte{0xa1, 0xb2, 0xc3, 0xd4, 0xe5, 0xf6, 0x07, 0x18}
+	txid, _ := hex.DecodeString("ef13a3f3ae3b703785d3010957a267c73664bd896e38b5a55428c5e5c175e0d0")
+	hash, _ := hex.DecodeString("bdb2b538e6b07e93d6bafcef4bec9dc936818a19")
+
+	coin := ByteWriter{}
+	coin.WriteVarInt(6257234*2 + 1)
+	coin.WriteVarInt(CompressAmount(1250000000))
+	coin.WriteVarInt(0)
+	coin.WriteBytes(hash)
+
+	db := mapChainstateDB{
+		string(chainstateObfuscateKey): append([]byte{0x08}, obfuscationKey...),
+		string(CoinKey(txid, 300)):     obfuscate(coin.Bytes, obfuscationKey),
+		string([]byte{'B'}):            obfuscate(txid, obfuscationKey),
+	}
+
+	chainstate, err := NewChainstate(db)
+	if err != nil {
+		t.Fatalf("Could not open chainstate; %s", err)
+	}
+
+	utxo, err := chainstate.Coin(txid, 300)
+	if err != nil {
+		t.Fatalf("Could not read coin; %s", err)
+	}
+
+	if utxo.Height != 6257234 || !utxo.IsCoinbase || utxo.Value != 1250000000 {
+		t.Errorf("Incorrect coin. Expected height %d, coinbase %t, value %d; got height %d, coinbase %t, value %d", 6257234, true, 1250000000, utxo.Height, utxo.IsCoinbase, utxo.Value)
+	}
+
+	if utxo.Script.String() != "76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac" {
+		t.Errorf("Incorrect coin script. Expected %s, got %s", "76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac", utxo.Script)
+	}
+
+	if _, err := chainstate.Coin(txid, 0); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for missing coin, got %v", err)
+	}
+
+	bestBlock, err := chainstate.BestBlock()
+	if err != nil || ToHexString(bestBlock) != ToHexString(txid) {
+		t.Errorf("Incorrect best block. Expected %x, got %x (%v)", txid, bestBlock, err)
+	}
+
+	entryTxid, entryVout, entryCoin, err := chainstate.DecodeEntry(CoinKey(txid, 300), db[string(CoinKey(txid, 300))])
+	if err != nil {
+		t.Fatalf("Could not decode entry; %s", err)
+	}
+
+	if entryTxid.String() != "d0e075c1e5c52854a5b5386e89bd6436c767a2570901d38537703baef3a313ef" || entryVout != 300 || entryCoin.Value != 1250000000 {
+		t.Errorf("Incorrect entry. Expected %s:%d, got %s:%d", "d0e075c1e5c52854a5b5386e89bd6436c767a2570901d38537703baef3a313ef", 300, entryTxid, entryVout)
+	}
+}
+
+// The chainstate entries for the first output of bitcoin block 1's
+// coinbase, which has never been spent, and the obfuscation key its value
+// is stored with, as raw LevelDB keys and values
+var btcChainstate = map[string]string{
+	"0e006f62667573636174655f6b6579":                                       "08b12dcefd8f872536",
+	"43982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e00": "b21fca6b3abfcd65e0b1bc97a316c328703bce539c17a40cd351a8060460b14d57119c",
+}
+
+func TestBitcoinChainstateEntry(t *testing.T) {
+	db := mapChainstateDB{}
+	for key, value := range btcChainstate {
+		keybytes, _ := hex.DecodeString(key)
+		valuebytes, _ := hex.DecodeString(value)
+		db[string(keybytes)] = valuebytes
+	}
+
+	chainstate, err := NewChainstate(db)
+	if err != nil {
+		t.Fatalf("Could not open chainstate; %s", err)
+	}
+
+	txid := hashFromHex("0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098")
+	utxo, err := chainstate.Coin(txid, 0)
+	if err != nil {
+		t.Fatalf("Could not read coin; %s", err)
+	}
+
+	expectedScript := "410496b538e853519c726a2c91e61ec11600ae1390813a627c66fb8be7947be63c52da7589379515d4e0a604f8141781e62294721166bf621e73a82cbf2342c858eeac"
+	if utxo.Height != 1 || !utxo.IsCoinbase || utxo.Value != 5000000000 || utxo.Script.String() != expectedScript {
+		t.Errorf("Incorrect coin. Expected height %d, coinbase %t, value %d, script %s; got height %d, coinbase %t, value %d, script %s", 1, true, 5000000000, expectedScript, utxo.Height, utxo.IsCoinbase, utxo.Value, utxo.Script)
+	}
+
+	address, _ := utxo.Script.Address(&AddressParamsBitcoin)
+	if address != "12c6DSiU4Rq3P4ZxziKxzrL5LmMBrzjrJX" {
+		t.Errorf("Incorrect coin address. Expected %s, got %s", "12c6DSiU4Rq3P4ZxziKxzrL5LmMBrzjrJX", address)
+	}
+
+	key, _ := hex.DecodeString("43982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e00")
+	entryTxid, entryVout, _, err := chainstate.DecodeEntry(key, db[string(key)])
+	if err != nil || !bytes.Equal(entryTxid, txid) || entryVout != 0 {
+		t.Errorf("Incorrect entry. Expected %s:%d, got %s:%d (%v)", txid, 0, entryTxid, entryVout, err)
+	}
+}
+
+func TestChainstateWithoutObfuscation(t *testing.T) {
+	txid := make([]byte, 32)
+	db := mapChainstateDB{
+		string(CoinKey(txid, 1)): {0x00, 0x00, 0x06},
+	}
+
+	chainstate, err := NewChainstate(db)
+	if err != nil {
+		t.Fatalf("Could not open chainstate; %s", err)
+	}
+
+	utxo, err := chainstate.Coin(txid, 1)
+	if err != nil {
+		t.Fatalf("Could not read coin; %s", err)
+	}
+
+	if utxo.Height != 0 || utxo.Value != 0 || len(utxo.Script) != 0 {
+		t.Errorf("Incorrect coin. Expected empty coin at height 0, got %d at height %d with script %s", utxo.Value, utxo.Height, utxo.Script)
+	}
+}
+
+func TestDecodeCoinKey(t *testing.T) {
+	key, _ := hex.DecodeString("43ef13a3f3ae3b703785d3010957a267c73664bd896e38b5a55428c5e5c175e0d08000")
+	txid, vout, err := DecodeCoinKey(key)
+	if err != nil {
+		t.Fatalf("Could not decode coin key; %s", err)
+	}
+
+	if txid.String() != "d0e075c1e5c52854a5b5386e89bd6436c767a2570901d38537703baef3a313ef" || vout != 128 {
+		t.Errorf("Incorrect outpoint. Expected %s:%d, got %s:%d", "d0e075c1e5c52854a5b5386e89bd6436c767a2570901d38537703baef3a313ef", 128, txid, vout)
+	}
+
+	if _, _, err := DecodeCoinKey(append([]byte{'B'}, key[1:]...)); err != ErrInvalidCoinKey {
+		t.Errorf("Expected ErrInvalidCoinKey for wrong prefix, got %v", err)
+	}
+
+	if _, _, err := DecodeCoinKey(key[:20]); !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF for truncated key, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package blockutils
+
+import (
+	"errors"
+	"math/big"
+)
+
+// Bitcoin's undo data and chainstate store outputs in a compressed form,
+// implemented in compressor.cpp. Amounts are stored with their trailing
+// zeros folded into an exponent, and the most common scripts are replaced
+// by a one byte type and the hash or key they contain.
+
+// The number of script types with a special compressed encoding. Other
+// scripts are stored with their length offset by this value
+const numSpecialScripts = 6
+
+// Compressed scripts longer than this are unspendable, and are decoded
+// as a single OP_RETURN, as bitcoin does
+const maxScriptSize = 10000
+
+// Returned when a compressed uncompressed-pubkey script holds an x
+// coordinate which is not on the secp256k1 curve
+var ErrInvalidPubKey = errors.New("Invalid public key")
+
+// secp256k1's field prime p, used to recover the y coordinate of
+// compressed public keys
+var secp256k1P, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+
+// A Coin is an unspent output as stored by bitcoin, along with the height
+// of the block and whether the tx which created it was a coinbase
+type Coin struct {
+	TxOutput
+	Height     uint32
+	IsCoinbase bool
+}
+
+// Compresses an amount as CompressAmount in bitcoin's compressor.cpp
+func CompressAmount(n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	e := uint64(0)
+	for n%10 == 0 && e < 9 {
+		n /= 10
+		e += 1
+	}
+
+	if e < 9 {
+		d := n % 10
+		n /= 10
+		return 1 + (n*9+d-1)*10 + e
+	}
+	return 1 + (n-1)*10 + 9
+}
+
+// Reverses CompressAmount
+func DecompressAmount(x uint64) uint64 {
+	if x == 0 {
+		return 0
+	}
+
+	x -= 1
+	e := x % 10
+	x /= 10
+
+	n := uint64(0)
+	if e < 9 {
+		d := x%9 + 1
+		x /= 9
+		n = x*10 + d
+	} else {
+		n = x + 1
+	}
+
+	for e > 0 {
+		n *= 10
+		e -= 1
+	}
+	return n
+}
+
+// Reads a script in bitcoin's compressed script format
+func readCompressedScript(r *ByteReader) (Script, error) {
+	size := r.ReadVarInt()
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	switch size {
+	case 0x00: // P2PKH
+		hash := r.ReadBytes(20)
+		script := append([]byte{0x76, 0xa9, 0x14}, hash...)
+		return append(script, 0x88, 0xac), r.Err()
+	case 0x01: // P2SH
+		hash := r.ReadBytes(20)
+		script := append([]byte{0xa9, 0x14}, hash...)
+		return append(script, 0x87), r.Err()
+	case 0x02, 0x03: // P2PK with a compressed key
+		x := r.ReadBytes(32)
+		script := append([]byte{0x21, byte(size)}, x...)
+		return append(script, 0xac), r.Err()
+	case 0x04, 0x05: // P2PK with an uncompressed key, stored compressed
+		x := r.ReadBytes(32)
+		if r.Err() != nil {
+			return nil, r.Err()
+		}
+
+		pubkey, err := decompressPubKey(byte(size-2), x)
+		if err != nil {
+			return nil, err
+		}
+		script := append([]byte{0x41}, pubkey...)
+		return append(script, 0xac), nil
+	}
+
+	size -= numSpecialScripts
+	if size > maxScriptSize {
+		r.ReadBytes(size)
+		return Script{0x6a}, r.Err()
+	}
+
+	script := r.ReadBytes(size)
+	return script, r.Err()
+}
+
+// Reads an output in bitcoin's compressed txout format
+func readCompressedTxOutput(r *ByteReader) (TxOutput, error) {
+	value := DecompressAmount(r.ReadVarInt())
+	script, err := readCompressedScript(r)
+	if err != nil {
+		return TxOutput{}, err
+	}
+
+	return TxOutput{
+		Value:  value,
+		Script: script,
+	}, nil
+}
+
+// Recovers the 65 byte uncompressed form of a public key from its x
+// coordinate and the 0x02 or 0x03 prefix indicating the parity of y
+func decompressPubKey(prefix byte, x []byte) ([]byte, error) {
+	xInt := new(big.Int).SetBytes(x)
+	if xInt.Cmp(secp256k1P) >= 0 {
+		return nil, ErrInvalidPubKey
+	}
+
+	// y^2 = x^3 + 7
+	ySquared := new(big.Int).Exp(xInt, big.NewInt(3), secp256k1P)
+	ySquared.Add(ySquared, big.NewInt(7))
+	ySquared.Mod(ySquared, secp256k1P)
+
+	y := new(big.Int).ModSqrt(ySquared, secp256k1P)
+	if y == nil {
+		return nil, ErrInvalidPubKey
+	}
+
+	if y.Bit(0) != uint(prefix&0x01) {
+		y.Sub(secp256k1P, y)
+	}
+
+	pubkey := make([]byte, 65)
+	pubkey[0] = 0x04
+	xInt.FillBytes(pubkey[1:33])
+	y.FillBytes(pubkey[33:65])
+	return pubkey, nil
+}
@@ -0,0 +1,74 @@
+package blockutils
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestCompressAmount(t *testing.T) {
+	tests := []struct {
+		amount     uint64
+		compressed uint64
+	}{
+		{0, 0x0},
+		{1, 0x1},
+		{1000000, 0x7},
+		{100000000, 0x9},
+		{5000000000, 0x32},
+		{2100000000000000, 0x1406f40},
+	}
+
+	for _, test := range tests {
+		if CompressAmount(test.amount) != test.compressed {
+			t.Errorf("Incorrect compressed amount for %d. Expected %x, got %x", test.amount, test.compressed, CompressAmount(test.amount))
+		}
+
+		if DecompressAmount(test.compressed) != test.amount {
+			t.Errorf("Incorrect decompressed amount for %x. Expected %d, got %d", test.compressed, test.amount, DecompressAmount(test.compressed))
+		}
+	}
+
+	for amount := uint64(0); amount < 100000; amount++ {
+		if DecompressAmount(CompressAmount(amount)) != amount {
+			t.Fatalf("Amount %d did not round trip", amount)
+		}
+	}
+}
+
+func TestReadCompressedScript(t *testing.T) {
+	tests := []struct {
+		compressed string
+		script     string
+	}{
+		{"00bdb2b538e6b07e93d6bafcef4bec9dc936818a19", "76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac"},
+		{"014aef67ed61d391d6f3d9903ead92386c1efc9925", "a9144aef67ed61d391d6f3d9903ead92386c1efc992587"},
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798ac"},
+		{"0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "410479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8ac"},
+		{"280020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d", "0020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d"},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.compressed)
+		reader := ByteReader{
+			Bytes:  data,
+			Cursor: 0,
+		}
+
+		script, err := readCompressedScript(&reader)
+		if err != nil {
+			t.Errorf("Could not read compressed script %s; %s", test.compressed, err)
+			continue
+		}
+
+		if script.String() != test.script {
+			t.Errorf("Incorrect script for %s. Expected %s, got %s", test.compressed, test.script, script)
+		}
+	}
+}
+
+func TestDecompressPubKeyInvalid(t *testing.T) {
+	x, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000005")
+	if _, err := decompressPubKey(0x02, x); err != ErrInvalidPubKey {
+		t.Errorf("Expected ErrInvalidPubKey for x not on the curve, got %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Returned when a block's merkle root does not match its transactions
+var ErrMerkleRootMismatch = errors.New("Merkle root does not match transactions")
+
+// Returned when a block's transactions have been mutated to produce the
+// same merkle root as another list of transactions (CVE-2012-2459). Such
+// a block may have a valid header while its transactions are not the
+// ones the miner committed to
+var ErrMerkleMutated = errors.New("Merkle tree is mutated")
+
+// Computes the merkle root of the given hashes, as ComputeMerkleRoot in
+// bitcoin's merkle.cpp.
+//
+// Each level of the tree hashes pairs of nodes together, duplicating the
+// last node if there is an odd number. This means a list of hashes and the
+// same list with its last hashes repeated have the same root. mutated is
+// set if two identical hashes are paired together, which is how such a
+// repeated list is detected
+func MerkleRoot(hashes []Hash256) (root Hash256, mutated bool) {
+	if len(hashes) == 0 {
+		return make(Hash256, 32), false
+	}
+
+	level := make([]Hash256, len(hashes))
+	copy(level, hashes)
+	for len(level) > 1 {
+		for pos := 0; pos+1 < len(level); pos += 2 {
+			if bytes.Equal(level[pos], level[pos+1]) {
+				mutated = true
+			}
+		}
+
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		next := make([]Hash256, len(level)/2)
+		for i := range next {
+			next[i] = hashMerkleBranches(level[2*i], level[2*i+1])
+		}
+		level = next
+	}
+
+	return level[0], mutated
+}
+
+// Returns the parent node of two nodes in a merkle tree
+func hashMerkleBranches(left Hash256, right Hash256) Hash256 {
+	pair := make([]byte, 0, 64)
+	pair = append(pair, left...)
+	pair = append(pair, right...)
+	return DoubleSha256(pair)
+}
+
+// Returns the TxId of each of the block's transactions, in order
+func (block *Block) TxIds() []Hash256 {
+	txids := make([]Hash256, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txids[i] = tx.TxId
+	}
+	return txids
+}
+
+// Computes the merkle root of the block's transactions. mutated is set if
+// the transactions have been tampered with in a way that keeps the same
+// root, see MerkleRoot
+func (block *Block) ComputeMerkleRoot() (root Hash256, mutated bool) {
+	return MerkleRoot(block.TxIds())
+}
+
+// Checks that the MerkleRoot in the block header commits to the block's
+// transactions, and that they have not been mutated. Blocks returned by
+// third party APIs can be checked this way against a trusted header
+func (block *Block) VerifyMerkleRoot() error {
+	root, mutated := block.ComputeMerkleRoot()
+	if !bytes.Equal(root, block.MerkleRoot) {
+		return ErrMerkleRootMismatch
+	}
+	if mutated {
+		return ErrMerkleMutated
+	}
+	return nil
+}
+
+// Returned when a tx is not part of the block
+var ErrTxNotFound = errors.New("Transaction not found in block")
+
+// Returned when a partial merkle tree is malformed
+var ErrInvalidMerkleBlock = errors.New("Invalid partial merkle tree")
+
+// A merkle branch proving that the tx TxId is at Index in a block, given
+// only the block's merkle root
+type MerkleProof struct {
+	TxId   Hash256
+	Index  uint32
+	Branch []Hash256
+}
+
+// Returns the sibling hashes from the leaf at index up to the root of the
+// merkle tree of hashes. Combined with the leaf, these recompute the root
+func MerkleBranch(hashes []Hash256, index uint32) []Hash256 {
+	if uint64(index) >= uint64(len(hashes)) {
+		return nil
+	}
+
+	branch := make([]Hash256, 0)
+	level := make([]Hash256, len(hashes))
+	copy(level, hashes)
+	for len(level) > 1 {
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		branch = append(branch, level[index^1])
+
+		next := make([]Hash256, len(level)/2)
+		for i := range next {
+			next[i] = hashMerkleBranches(level[2*i], level[2*i+1])
+		}
+		level = next
+		index >>= 1
+	}
+	return branch
+}
+
+// Returns a merkle proof that the tx with the given txid is in the block
+func (block *Block) MerkleProof(txid Hash256) (*MerkleProof, error) {
+	txids := block.TxIds()
+	for i, blocktxid := range txids {
+		if bytes.Equal(blocktxid, txid) {
+			return &MerkleProof{
+				TxId:   blocktxid,
+				Index:  uint32(i),
+				Branch: MerkleBranch(txids, uint32(i)),
+			}, nil
+		}
+	}
+	return nil, ErrTxNotFound
+}
+
+// Returns the merkle root the proof's branch leads to
+func (proof *MerkleProof) Root() Hash256 {
+	hash := proof.TxId
+	for i, sibling := range proof.Branch {
+		if (proof.Index>>uint(i))&0x01 == 0x01 {
+			hash = hashMerkleBranches(sibling, hash)
+		} else {
+			hash = hashMerkleBranches(hash, sibling)
+		}
+	}
+	return hash
+}
+
+// Returns true if the proof leads to merkleRoot, which should come from a
+// trusted block header
+func (proof *MerkleProof) Verify(merkleRoot Hash256) bool {
+	return bytes.Equal(proof.Root(), merkleRoot)
+}
+
+// A block header along with a partial merkle tree proving some of the
+// block's txs are included in it, as returned by gettxoutproof and sent
+// in the merkleblock p2p message (BIP37).
+//
+// The tree is walked depth first. Flags holds one bit per node visited,
+// least significant bit first, which is set if the node is an ancestor of
+// a matched tx. Hashes holds the hash of each node which is not walked
+// into: unmatched subtrees, and matched txs themselves
+type MerkleBlock struct {
+	BlockHeader
+	TotalTransactions uint32
+	Hashes            []Hash256
+	Flags             []byte
+}
+
+// Returns a merkle block parsed from the given hexstring (such as from
+// gettxoutproof)
+func NewMerkleBlockFromHexString(hexstring string) (*MerkleBlock, error) {
+	merkleblockbytes, err := hex.DecodeString(hexstring)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMerkleBlockFromBytes(merkleblockbytes)
+}
+
+// Returns a merkle block parsed from the given bytes
+func NewMerkleBlockFromBytes(merkleblockbytes []byte) (*MerkleBlock, error) {
+	r := ByteReader{
+		Bytes:  merkleblockbytes,
+		Cursor: 0,
+	}
+
+	header, err := readBlockHeader(&r) // A merkle block starts with the block header
+	if err != nil {
+		return nil, fmt.Errorf("Could not read block header: %w", err)
+	}
+
+	totaltxs := r.ReadUint32()             // ... followed by the number of txs in the block
+	hashcount := r.readCount("hash count") // ... followed by the hashes
+	hashes := make([]Hash256, 0, preallocSize(hashcount))
+	i := uint64(0)
+	for i < hashcount && r.Err() == nil {
+		hashes = append(hashes, r.ReadBytes(32))
+		i += 1
+	}
+	flaglength := r.readCount("flag length") // ... followed by the flag bits
+	flags := r.ReadBytes(flaglength)
+	if r.Err() != nil {
+		return nil, fmt.Errorf("Could not read partial merkle tree: %w", r.Err())
+	}
+
+	return &MerkleBlock{
+		BlockHeader:       *header,
+		TotalTransactions: totaltxs,
+		Hashes:            hashes,
+		Flags:             flags,
+	}, nil
+}
+
+// Builds a merkle block proving the txs with the given txids are in the
+// block, as gettxoutproof does
+func NewMerkleBlock(block *Block, txids []Hash256) (*MerkleBlock, error) {
+	blocktxids := block.TxIds()
+	matches := make([]bool, len(blocktxids))
+	for _, txid := range txids {
+		found := false
+		for i, blocktxid := range blocktxids {
+			if bytes.Equal(blocktxid, txid) {
+				matches[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: %w", txid, ErrTxNotFound)
+		}
+	}
+
+	tree := partialMerkleTree{
+		total:  uint32(len(blocktxids)),
+		txids:  blocktxids,
+		hashes: make([]Hash256, 0),
+	}
+	tree.build(tree.height(), 0, matches)
+
+	return &MerkleBlock{
+		BlockHeader:       block.BlockHeader,
+		TotalTransactions: tree.total,
+		Hashes:            tree.hashes,
+		Flags:             tree.flagBytes(),
+	}, nil
+}
+
+// Returns the merkle block in its wire format
+func (mb *MerkleBlock) Serialize() []byte {
+	writer := ByteWriter{}
+	mb.BlockHeader.writeTo(&writer)
+	writer.WriteUint32(mb.TotalTransactions)
+	writer.WriteCompactSizeUint(uint64(len(mb.Hashes)))
+	for _, hash := range mb.Hashes {
+		writer.WriteBytes(hash)
+	}
+	writer.WriteVarBytes(mb.Flags)
+	return writer.Bytes
+}
+
+// Returns the hex encoded wire format of the merkle block, as returned by
+// gettxoutproof
+func (mb *MerkleBlock) Hex() string {
+	return hex.EncodeToString(mb.Serialize())
+}
+
+// Walks the partial merkle tree, returning the txids it proves are in the
+// block and their positions. Returns ErrMerkleRootMismatch if the tree
+// does not lead to the header's merkle root
+func (mb *MerkleBlock) ExtractMatches() ([]Hash256, []uint32, error) {
+	if mb.TotalTransactions == 0 || uint64(len(mb.Hashes)) > uint64(mb.TotalTransactions) || len(mb.Flags)*8 < len(mb.Hashes) {
+		return nil, nil, ErrInvalidMerkleBlock
+	}
+
+	tree := partialMerkleTree{
+		total:  mb.TotalTransactions,
+		hashes: mb.Hashes,
+		flags:  mb.Flags,
+	}
+	root, err := tree.extract(tree.height(), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Every hash, and every byte of flags, must have been used
+	if (tree.flagsUsed+7)/8 != len(mb.Flags) || tree.hashesUsed != len(mb.Hashes) {
+		return nil, nil, ErrInvalidMerkleBlock
+	}
+
+	if !bytes.Equal(root, mb.MerkleRoot) {
+		return nil, nil, ErrMerkleRootMismatch
+	}
+	return tree.matches, tree.indexes, nil
+}
+
+// Builds and walks partial merkle trees, as CPartialMerkleTree in
+// bitcoin's merkleblock.cpp
+type partialMerkleTree struct {
+	total  uint32
+	txids  []Hash256
+	hashes []Hash256
+	flags  []byte
+	bits   []bool
+
+	flagsUsed  int
+	hashesUsed int
+	matches    []Hash256
+	indexes    []uint32
+}
+
+// Returns the number of nodes at height in the tree, where the txs are at
+// height 0
+func (tree *partialMerkleTree) width(height uint) uint64 {
+	return (uint64(tree.total) + (1 << height) - 1) >> height
+}
+
+// Returns the height of the tree's root
+func (tree *partialMerkleTree) height() uint {
+	height := uint(0)
+	for tree.width(height) > 1 {
+		height += 1
+	}
+	return height
+}
+
+// Returns the hash of the node at pos in height, from the full list of txids
+func (tree *partialMerkleTree) hash(height uint, pos uint64) Hash256 {
+	if height == 0 {
+		return tree.txids[pos]
+	}
+
+	left := tree.hash(height-1, pos*2)
+	right := left
+	if pos*2+1 < tree.width(height-1) {
+		right = tree.hash(height-1, pos*2+1)
+	}
+	return hashMerkleBranches(left, right)
+}
+
+func (tree *partialMerkleTree) build(height uint, pos uint64, matches []bool) {
+	parentOfMatch := false
+	for p := pos << height; p < (pos+1)<<height && p < uint64(tree.total); p++ {
+		parentOfMatch = parentOfMatch || matches[p]
+	}
+	tree.bits = append(tree.bits, parentOfMatch)
+
+	if height == 0 || !parentOfMatch {
+		tree.hashes = append(tree.hashes, tree.hash(height, pos))
+		return
+	}
+
+	tree.build(height-1, pos*2, matches)
+	if pos*2+1 < tree.width(height-1) {
+		tree.build(height-1, pos*2+1, matches)
+	}
+}
+
+// Packs the bits gathered by build into bytes, least significant bit first
+func (tree *partialMerkleTree) flagBytes() []byte {
+	flags := make([]byte, (len(tree.bits)+7)/8)
+	for i, bit := range tree.bits {
+		if bit {
+			flags[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return flags
+}
+
+func (tree *partialMerkleTree) extract(height uint, pos uint64) (Hash256, error) {
+	if tree.flagsUsed >= len(tree.flags)*8 {
+		return nil, ErrInvalidMerkleBlock
+	}
+	parentOfMatch := tree.flags[tree.flagsUsed/8]&(1<<uint(tree.flagsUsed%8)) != 0
+	tree.flagsUsed += 1
+
+	if height == 0 || !parentOfMatch {
+		if tree.hashesUsed >= len(tree.hashes) {
+			return nil, ErrInvalidMerkleBlock
+		}
+		hash := tree.hashes[tree.hashesUsed]
+		tree.hashesUsed += 1
+
+		if height == 0 && parentOfMatch {
+			tree.matches = append(tree.matches, hash)
+			tree.indexes = append(tree.indexes, uint32(pos))
+		}
+		return hash, nil
+	}
+
+	left, err := tree.extract(height-1, pos*2)
+	if err != nil {
+		return nil, err
+	}
+
+	right := left
+	if pos*2+1 < tree.width(height-1) {
+		right, err = tree.extract(height-1, pos*2+1)
+		if err != nil {
+			return nil, err
+		}
+
+		// Identical siblings allow the same CVE-2012-2459 mutation as in
+		// full blocks
+		if bytes.Equal(left, right) {
+			return nil, ErrMerkleMutated
+		}
+	}
+	return hashMerkleBranches(left, right), nil
+}
@@ -0,0 +1,208 @@
+package blockutils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBlockMerkleRoot(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	root, mutated := block.ComputeMerkleRoot()
+	if root.String() != "490ae38cfaf7be4ba11c21b6bda4b9757842d0990414ec0f7a7d69ed81dd9b0b" {
+		t.Errorf("Incorrect merkle root. Expected %s, got %s", "490ae38cfaf7be4ba11c21b6bda4b9757842d0990414ec0f7a7d69ed81dd9b0b", root)
+	}
+
+	if mutated {
+		t.Error("Block incorrectly detected as mutated")
+	}
+
+	if err := block.VerifyMerkleRoot(); err != nil {
+		t.Errorf("Merkle root did not verify; %s", err)
+	}
+
+	block.Transactions = block.Transactions[:2]
+	if err := block.VerifyMerkleRoot(); err != ErrMerkleRootMismatch {
+		t.Errorf("Expected ErrMerkleRootMismatch with a missing tx, got %v", err)
+	}
+}
+
+func TestBlockMerkleRootMutated(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	// Repeating the last tx of an odd length list keeps the same root
+	block.Transactions = append(block.Transactions, block.Transactions[2])
+	root, mutated := block.ComputeMerkleRoot()
+	if root.String() != "490ae38cfaf7be4ba11c21b6bda4b9757842d0990414ec0f7a7d69ed81dd9b0b" {
+		t.Errorf("Incorrect merkle root. Expected %s, got %s", "490ae38cfaf7be4ba11c21b6bda4b9757842d0990414ec0f7a7d69ed81dd9b0b", root)
+	}
+
+	if !mutated {
+		t.Error("Failed to detect mutated block")
+	}
+
+	if err := block.VerifyMerkleRoot(); err != ErrMerkleMutated {
+		t.Errorf("Expected ErrMerkleMutated, got %v", err)
+	}
+}
+
+func TestMerkleRootSingle(t *testing.T) {
+	block, _ := NewBlockFromHexString("01000000eb68047fb29d78480b567ef6b76be556a2ec975656424508cc1c69b700000000bad58718fc3c6f5474918f06c44400c70b4c86d55a3f3ca3493b1d40c2061f2ba00f6b49ffff001d064b3a6d0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff0704ffff001d0138ffffffff0100f2052a010000004341045e071dedd1ed03721c6e9bba28fc276795421a378637fb41090192bb9f208630dcbac5862a3baeb9df3ca6e4e256b7fd2404824c20198ca1b004ee2197866433ac00000000")
+
+	if err := block.VerifyMerkleRoot(); err != nil {
+		t.Errorf("Merkle root did not verify; %s", err)
+	}
+}
+
+func TestBlockMerkleProof(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	for i, tx := range block.Transactions {
+		proof, err := block.MerkleProof(tx.TxId)
+		if err != nil {
+			t.Fatalf("Could not create merkle proof for tx %d; %s", i, err)
+		}
+
+		if proof.Index != uint32(i) {
+			t.Errorf("Incorrect index for tx %d. Expected %d, got %d", i, i, proof.Index)
+		}
+
+		if !proof.Verify(block.MerkleRoot) {
+			t.Errorf("Merkle proof for tx %d did not verify", i)
+		}
+
+		// The last tx is paired with itself, so its sibling position
+		// leads to the same root
+		proof.Index ^= 1
+		if i < 2 && proof.Verify(block.MerkleRoot) {
+			t.Errorf("Merkle proof for tx %d verified with the wrong index", i)
+		}
+	}
+
+	if _, err := block.MerkleProof(block.MerkleRoot); err != ErrTxNotFound {
+		t.Errorf("Expected ErrTxNotFound, got %v", err)
+	}
+}
+
+func TestMerkleBlock(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	merkleblock, err := NewMerkleBlock(block, []Hash256{block.Transactions[1].TxId})
+	if err != nil {
+		t.Fatalf("Could not create merkle block; %s", err)
+	}
+
+	// Header, tx count, 3 hashes and 1 byte of flags
+	proofhex := merkleblock.Hex()
+	if len(proofhex) != 2*(80+4+1+3*32+1+1) {
+		t.Errorf("Incorrect merkle block length. Expected %d, got %d", 2*(80+4+1+3*32+1+1), len(proofhex))
+	}
+
+	decoded, err := NewMerkleBlockFromHexString(proofhex)
+	if err != nil {
+		t.Fatalf("Could not parse merkle block; %s", err)
+	}
+
+	if decoded.Hash().String() != block.Hash.String() {
+		t.Errorf("Incorrect merkle block hash. Expected %s, got %s", block.Hash, decoded.Hash())
+	}
+
+	matches, indexes, err := decoded.ExtractMatches()
+	if err != nil {
+		t.Fatalf("Could not extract matches; %s", err)
+	}
+
+	if len(matches) != 1 || matches[0].String() != dgb6257234TxHashes[1] || indexes[0] != 1 {
+		t.Errorf("Incorrect matches. Expected [%s] at [1], got %v at %v", dgb6257234TxHashes[1], matches, indexes)
+	}
+
+	decoded.Hashes[0] = decoded.Hashes[2]
+	if _, _, err := decoded.ExtractMatches(); err != ErrMerkleRootMismatch {
+		t.Errorf("Expected ErrMerkleRootMismatch for tampered tree, got %v", err)
+	}
+
+	decoded.Hashes = decoded.Hashes[:2]
+	if _, _, err := decoded.ExtractMatches(); err != ErrInvalidMerkleBlock {
+		t.Errorf("Expected ErrInvalidMerkleBlock for missing hash, got %v", err)
+	}
+}
+
+// gettxoutproof output for the coinbase of bitcoin block 1, and for
+// f4184fc5..., the first transaction between two people, in block 170
+var btc1TxOutProof = "010000006fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e61bc6649ffff001d01e362990100000001982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e0101"
+var btc170TxOutProof = "0100000055bd840a78798ad0da853f68974f3d183e2bd1db6a842c1feecf222a00000000ff104ccb05421ab93e63f8c3ce5c2c2e9dbb37de2764b3a3175c8166562cac7d51b96a49ffff001d283e9e70020000000282501c1178fa0b222c1f3d474ec726b832013f0a532b44bb620cce8624a5feb1169e1e83e930853391bc6f35f605c6754cfead57cf8387639d3b4096c54f18f40105"
+
+func TestMerkleBlockTxOutProof(t *testing.T) {
+	var tests = []struct {
+		proof     string
+		blockhash string
+		txids     []string
+		index     uint32
+	}{
+		{btc1TxOutProof, "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048", []string{"0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098"}, 0},
+		{btc170TxOutProof, "00000000d1145790a8694403d4063f323d499e655c83426834d4ce2f8dd4a2ee", []string{"b1fea52486ce0c62bb442b530a3f0132b826c74e473d1f2c220bfa78111c5082", "f4184fc596403b9d638783cf57adfe4c75c605f6356fbc91338530e9831e9e16"}, 1},
+	}
+
+	for _, test := range tests {
+		merkleblock, err := NewMerkleBlockFromHexString(test.proof)
+		if err != nil {
+			t.Fatalf("Could not parse merkle block; %s", err)
+		}
+
+		if merkleblock.Hash().String() != test.blockhash {
+			t.Errorf("Incorrect merkle block hash. Expected %s, got %s", test.blockhash, merkleblock.Hash())
+		}
+
+		if merkleblock.TotalTransactions != uint32(len(test.txids)) {
+			t.Errorf("Incorrect tx count. Expected %d, got %d", len(test.txids), merkleblock.TotalTransactions)
+		}
+
+		matches, indexes, err := merkleblock.ExtractMatches()
+		if err != nil {
+			t.Fatalf("Could not extract matches; %s", err)
+		}
+
+		txid := test.txids[test.index]
+		if len(matches) != 1 || matches[0].String() != txid || indexes[0] != test.index {
+			t.Errorf("Incorrect matches. Expected [%s] at [%d], got %v at %v", txid, test.index, matches, indexes)
+		}
+
+		// Building the proof from the block's txids should give the same
+		// bytes bitcoin does
+		block := &Block{BlockHeader: merkleblock.BlockHeader}
+		for _, blocktxid := range test.txids {
+			block.Transactions = append(block.Transactions, &Transaction{TxId: hashFromHex(blocktxid)})
+		}
+
+		built, err := NewMerkleBlock(block, []Hash256{hashFromHex(txid)})
+		if err != nil {
+			t.Fatalf("Could not create merkle block; %s", err)
+		}
+		if built.Hex() != test.proof {
+			t.Errorf("Incorrect merkle block hex. Expected %s, got %s", test.proof, built.Hex())
+		}
+	}
+}
+
+func TestMerkleBlockMultipleMatches(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	txids := block.TxIds()
+	merkleblock, err := NewMerkleBlock(block, []Hash256{txids[0], txids[2]})
+	if err != nil {
+		t.Fatalf("Could not create merkle block; %s", err)
+	}
+
+	matches, indexes, err := merkleblock.ExtractMatches()
+	if err != nil {
+		t.Fatalf("Could not extract matches; %s", err)
+	}
+
+	if len(matches) != 2 || matches[0].String() != dgb6257234TxHashes[0] || matches[1].String() != dgb6257234TxHashes[2] || indexes[1] != 2 {
+		t.Errorf("Incorrect matches. Expected [%s %s] at [0 2], got %v at %v", dgb6257234TxHashes[0], dgb6257234TxHashes[2], matches, indexes)
+	}
+
+	if _, err := NewMerkleBlock(block, []Hash256{block.MerkleRoot}); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("Expected ErrTxNotFound, got %v", err)
+	}
+}
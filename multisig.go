@@ -0,0 +1,64 @@
+package blockutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Returned when a script is not a bare multisig script
+var ErrNotMultisig = errors.New("Not a multisig script")
+
+// Returned when a multisig script can not be built from the given keys
+var ErrInvalidMultisig = errors.New("Invalid multisig parameters")
+
+// Returns true if the script is "m <pubkey>... n OP_CHECKMULTISIG", as
+// found in bare multisig outputs and P2SH or P2WSH redeem scripts
+func (script Script) IsMultisig() bool {
+	_, _, ok := script.matchMultisig()
+	return ok
+}
+
+// Returns the number of signatures a multisig script requires, m, the
+// number of keys it has, n, and the keys
+func (script Script) Multisig() (int, int, [][]byte, error) {
+	m, pubkeys, ok := script.matchMultisig()
+	if !ok {
+		return 0, 0, nil, ErrNotMultisig
+	}
+	return m, len(pubkeys), pubkeys, nil
+}
+
+// Returns a script requiring m signatures from the given keys. When sorted
+// is set, the keys are ordered as described in BIP67, which only allows
+// compressed keys, so that the script does not depend on the order the
+// keys were given in
+func NewMultisigScript(m int, pubkeys [][]byte, sorted bool) (Script, error) {
+	if m < 1 || m > len(pubkeys) || len(pubkeys) > maxPubKeysPerMultisig {
+		return nil, fmt.Errorf("%d of %d: %w", m, len(pubkeys), ErrInvalidMultisig)
+	}
+
+	for i, pubkey := range pubkeys {
+		if !isValidPubKeySize(pubkey) {
+			return nil, fmt.Errorf("Public key %d: %w", i, ErrInvalidPubKey)
+		}
+		if sorted && len(pubkey) != 33 {
+			return nil, fmt.Errorf("Public key %d is not compressed, as BIP67 requires: %w", i, ErrInvalidMultisig)
+		}
+	}
+
+	if sorted {
+		pubkeys = append([][]byte{}, pubkeys...)
+		sort.Slice(pubkeys, func(i, j int) bool {
+			return bytes.Compare(pubkeys[i], pubkeys[j]) < 0
+		})
+	}
+
+	script := appendScriptNum(Script{}, int64(m))
+	for _, pubkey := range pubkeys {
+		script = appendPushData(script, pubkey)
+	}
+	script = appendScriptNum(script, int64(len(pubkeys)))
+	return append(script, OP_CHECKMULTISIG), nil
+}
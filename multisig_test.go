@@ -0,0 +1,140 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func decodeHexKeys(hexkeys ...string) [][]byte {
+	keys := make([][]byte, len(hexkeys))
+	for i, hexkey := range hexkeys {
+		keys[i], _ = hex.DecodeString(hexkey)
+	}
+	return keys
+}
+
+// Test vector 1 from BIP67
+func TestNewMultisigScriptSorted(t *testing.T) {
+	keys := decodeHexKeys(
+		"02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8",
+		"02fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f",
+	)
+
+	script, err := NewMultisigScript(2, keys, true)
+	if err != nil {
+		t.Fatalf("Could not build multisig script: %s", err)
+	}
+
+	expected := "522102fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f2102ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f852ae"
+	if script.String() != expected {
+		t.Errorf("Incorrect multisig script. Expected %s, got %s", expected, script)
+	}
+
+	address, _ := newP2SHScript(Hash160(script)).Address(&AddressParamsBitcoin)
+	if address != "39bgKC7RFbpoCRbtD5KEdkYKtNyhpsNa3Z" {
+		t.Errorf("Incorrect P2SH address. Expected %s, got %s", "39bgKC7RFbpoCRbtD5KEdkYKtNyhpsNa3Z", address)
+	}
+
+	// The keys given are left in their original order
+	if keys[0][1] != 0xff {
+		t.Error("Sorting modified the given keys")
+	}
+
+	unsorted, _ := NewMultisigScript(2, keys, false)
+	if unsorted.String() == expected {
+		t.Error("Keys were sorted without the sorted option")
+	}
+}
+
+func TestScriptMultisig(t *testing.T) {
+	keys := decodeHexKeys(
+		"031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa",
+		"04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f",
+		"02f24f8135e2f62f81d6c4ff172fd2681a3e03cf7485510a2871ca2c41b5aa9733",
+	)
+
+	script, err := NewMultisigScript(2, keys, false)
+	if err != nil {
+		t.Fatalf("Could not build multisig script: %s", err)
+	}
+	if script.Disassemble() != "2 "+hex.EncodeToString(keys[0])+" "+hex.EncodeToString(keys[1])+" "+hex.EncodeToString(keys[2])+" 3 OP_CHECKMULTISIG" {
+		t.Errorf("Incorrect multisig script. Got %s", script.Disassemble())
+	}
+
+	if !script.IsMultisig() || script.Type() != ScriptMultisig {
+		t.Error("Incorrectly declared script as non-multisig")
+	}
+
+	m, n, pubkeys, err := script.Multisig()
+	if err != nil {
+		t.Fatalf("Could not read multisig script: %s", err)
+	}
+	if m != 2 || n != 3 || len(pubkeys) != 3 {
+		t.Errorf("Incorrect multisig. Expected %d of %d, got %d of %d", 2, 3, m, n)
+	}
+	for i := range keys {
+		if !bytes.Equal(pubkeys[i], keys[i]) {
+			t.Errorf("Incorrect key %d. Expected %x, got %x", i, keys[i], pubkeys[i])
+		}
+	}
+}
+
+func TestScriptMultisigLarge(t *testing.T) {
+	keys := [][]byte{}
+	for i := 0; i < 20; i++ {
+		key := make([]byte, 33)
+		key[0] = 0x02
+		key[32] = byte(i)
+		keys = append(keys, key)
+	}
+
+	script, err := NewMultisigScript(17, keys, true)
+	if err != nil {
+		t.Fatalf("Could not build multisig script: %s", err)
+	}
+
+	// Numbers above 16 are pushed as data
+	if script[0] != 0x01 || script[1] != 17 || script[len(script)-3] != 0x01 || script[len(script)-2] != 20 {
+		t.Errorf("Incorrect m or n encoding in %s", script)
+	}
+
+	m, n, _, err := script.Multisig()
+	if err != nil || m != 17 || n != 20 {
+		t.Errorf("Incorrect multisig. Expected %d of %d, got %d of %d (%v)", 17, 20, m, n, err)
+	}
+}
+
+func TestMultisigErrors(t *testing.T) {
+	keys := decodeHexKeys(
+		"031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa",
+		"02f24f8135e2f62f81d6c4ff172fd2681a3e03cf7485510a2871ca2c41b5aa9733",
+	)
+	uncompressed := decodeHexKeys("04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f")
+
+	var tests = []struct {
+		m      int
+		keys   [][]byte
+		sorted bool
+		err    error
+	}{
+		{0, keys, false, ErrInvalidMultisig},
+		{3, keys, false, ErrInvalidMultisig},
+		{1, nil, false, ErrInvalidMultisig},
+		{1, append(keys, make([][]byte, 19)...), false, ErrInvalidMultisig},
+		{1, decodeHexKeys("051ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa"), false, ErrInvalidPubKey},
+		{1, uncompressed, true, ErrInvalidMultisig},
+	}
+
+	for i, test := range tests {
+		if _, err := NewMultisigScript(test.m, test.keys, test.sorted); !errors.Is(err, test.err) {
+			t.Errorf("Incorrect error for test %d. Expected %s, got %v", i, test.err, err)
+		}
+	}
+
+	script, _ := hex.DecodeString("76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac")
+	if _, _, _, err := Script(script).Multisig(); !errors.Is(err, ErrNotMultisig) {
+		t.Errorf("Incorrect error for P2PKH script. Expected %s, got %v", ErrNotMultisig, err)
+	}
+}
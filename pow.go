@@ -0,0 +1,146 @@
+package blockutils
+
+import (
+	"errors"
+	"math/big"
+)
+
+// The easiest target, or highest hash, a block is allowed to have on each
+// chain. Difficulty is measured relative to these
+var (
+	PowLimitBitcoin        = hexToBig("00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	PowLimitBitcoinSignet  = hexToBig("00000377ae000000000000000000000000000000000000000000000000000000")
+	PowLimitBitcoinRegtest = hexToBig("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	PowLimitLitecoin       = hexToBig("00000fffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	PowLimitDogecoin       = hexToBig("00000fffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	PowLimitDigiByte       = hexToBig("00000fffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+)
+
+// Returned when NBits does not encode a usable target: one that is zero,
+// negative, overflows 256 bits or is easier than the chain's pow limit
+var ErrInvalidTarget = errors.New("Invalid proof of work target")
+
+// Returned when a block hash is above its target
+var ErrHighHash = errors.New("Hash does not meet proof of work target")
+
+func hexToBig(hexstring string) *big.Int {
+	n, _ := new(big.Int).SetString(hexstring, 16)
+	return n
+}
+
+// Decodes a target from the compact format used by NBits, as SetCompact
+// in bitcoin's arith_uint256.cpp.
+//
+// The top byte is a base 256 exponent giving the length of the number in
+// bytes, and the low 23 bits are its most significant digits. Bit 23 is a
+// sign bit, so encodings with it set result in a negative number. Targets
+// too large for 256 bits are returned as is, see CheckProofOfWork
+func CompactToBig(compact uint32) *big.Int {
+	size := compact >> 24
+	word := int64(compact & 0x007fffff)
+
+	target := big.NewInt(word)
+	if size <= 3 {
+		target.Rsh(target, uint(8*(3-size)))
+	} else {
+		target.Lsh(target, uint(8*(size-3)))
+	}
+
+	if word != 0 && compact&0x00800000 != 0 {
+		target.Neg(target)
+	}
+	return target
+}
+
+// Encodes a number in the compact format used by NBits, as GetCompact in
+// bitcoin's arith_uint256.cpp. Precision beyond the top 3 bytes is lost
+func BigToCompact(n *big.Int) uint32 {
+	abs := new(big.Int).Abs(n)
+	size := uint32(len(abs.Bytes()))
+
+	compact := uint32(0)
+	if size <= 3 {
+		compact = uint32(abs.Uint64()) << (8 * (3 - size))
+	} else {
+		compact = uint32(new(big.Int).Rsh(abs, uint(8*(size-3))).Uint64())
+	}
+
+	// The sign bit can't be part of the mantissa, so shift it out of the
+	// way if needed
+	if compact&0x00800000 != 0 {
+		compact >>= 8
+		size += 1
+	}
+
+	compact |= size << 24
+	if n.Sign() < 0 && compact&0x007fffff != 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// Returns the expected number of hashes needed to find a block meeting
+// the target encoded by bits, as GetBlockProof in bitcoin's chain.cpp.
+// The chainwork of a block is the sum of the work of it and its ancestors
+func CalcWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 || target.BitLen() > 256 {
+		return new(big.Int)
+	}
+
+	// 2**256 / (target+1)
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+	return work.Div(work, target.Add(target, big.NewInt(1)))
+}
+
+// Checks that hash meets the target encoded by bits, and that the target
+// is valid for a chain with the given pow limit, as CheckProofOfWork in
+// bitcoin's pow.cpp
+func CheckProofOfWork(hash Hash256, bits uint32, powLimit *big.Int) error {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 || target.BitLen() > 256 || target.Cmp(powLimit) > 0 {
+		return ErrInvalidTarget
+	}
+
+	// Hashes are stored in little endian byte order
+	if new(big.Int).SetBytes(ReverseHex(hash)).Cmp(target) > 0 {
+		return ErrHighHash
+	}
+	return nil
+}
+
+// Returns the target the block hash must not exceed, decoded from NBits
+func (header *BlockHeader) Target() *big.Int {
+	return CompactToBig(header.NBits)
+}
+
+// Returns how many times harder the block's target is to meet than the
+// chain's pow limit. As with getdifficulty, the limit is first rounded to
+// its compact form, so a bitcoin block with NBits 0x1d00ffff has a
+// difficulty of exactly 1
+func (header *BlockHeader) Difficulty(powLimit *big.Int) float64 {
+	target := header.Target()
+	if target.Sign() <= 0 {
+		return 0
+	}
+
+	limit := CompactToBig(BigToCompact(powLimit))
+	difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(limit), new(big.Float).SetInt(target)).Float64()
+	return difficulty
+}
+
+// Returns the work done by the block, see CalcWork
+func (header *BlockHeader) Work() *big.Int {
+	return CalcWork(header.NBits)
+}
+
+// Checks that the block hash meets the block's target, which must be
+// valid for a chain with the given pow limit.
+//
+// This only applies to chains whose proof of work hash is the block hash,
+// such as bitcoin. Scrypt chains like Litecoin and Dogecoin, and DigiByte's
+// multi-algo blocks, should instead pass their proof of work hash to the
+// CheckProofOfWork function
+func (header *BlockHeader) CheckProofOfWork(powLimit *big.Int) error {
+	return CheckProofOfWork(header.Hash(), header.NBits, powLimit)
+}
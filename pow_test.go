@@ -0,0 +1,105 @@
+package blockutils
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestCompactToBig(t *testing.T) {
+	tests := []struct {
+		compact   uint32
+		target    string
+		recompact uint32
+	}{
+		{0x00000000, "0", 0x00000000},
+		{0x00123456, "0", 0x00000000},
+		{0x01003456, "0", 0x00000000},
+		{0x02000056, "0", 0x00000000},
+		{0x03000000, "0", 0x00000000},
+		{0x04000000, "0", 0x00000000},
+		{0x00923456, "0", 0x00000000},
+		{0x01803456, "0", 0x00000000},
+		{0x01123456, "12", 0x01120000},
+		{0x01fedcba, "-7e", 0x01fe0000},
+		{0x02123456, "1234", 0x02123400},
+		{0x03123456, "123456", 0x03123456},
+		{0x04123456, "12345600", 0x04123456},
+		{0x04923456, "-12345600", 0x04923456},
+		{0x05009234, "92340000", 0x05009234},
+		{0x20123456, "1234560000000000000000000000000000000000000000000000000000000000", 0x20123456},
+		{0x1d00ffff, "ffff0000000000000000000000000000000000000000000000000000", 0x1d00ffff},
+	}
+
+	for _, test := range tests {
+		target := CompactToBig(test.compact)
+		if target.Text(16) != test.target {
+			t.Errorf("Incorrect target for %08x. Expected %s, got %s", test.compact, test.target, target.Text(16))
+		}
+
+		if BigToCompact(target) != test.recompact {
+			t.Errorf("Incorrect compact for %s. Expected %08x, got %08x", test.target, test.recompact, BigToCompact(target))
+		}
+	}
+
+	if BigToCompact(big.NewInt(0x80)) != 0x02008000 {
+		t.Errorf("Incorrect compact for 0x80. Expected %08x, got %08x", 0x02008000, BigToCompact(big.NewInt(0x80)))
+	}
+
+	if BigToCompact(PowLimitBitcoin) != 0x1d00ffff {
+		t.Errorf("Incorrect compact for bitcoin pow limit. Expected %08x, got %08x", 0x1d00ffff, BigToCompact(PowLimitBitcoin))
+	}
+}
+
+func TestBlockProofOfWork(t *testing.T) {
+	btc200, _ := NewBlockHeaderFromHexString("01000000eb68047fb29d78480b567ef6b76be556a2ec975656424508cc1c69b700000000bad58718fc3c6f5474918f06c44400c70b4c86d55a3f3ca3493b1d40c2061f2ba00f6b49ffff001d064b3a6d")
+
+	if err := btc200.CheckProofOfWork(PowLimitBitcoin); err != nil {
+		t.Errorf("Proof of work did not verify; %s", err)
+	}
+
+	if btc200.Difficulty(PowLimitBitcoin) != 1 {
+		t.Errorf("Incorrect difficulty. Expected %f, got %f", 1.0, btc200.Difficulty(PowLimitBitcoin))
+	}
+
+	if btc200.Work().Cmp(big.NewInt(4295032833)) != 0 {
+		t.Errorf("Incorrect work. Expected %d, got %s", 4295032833, btc200.Work())
+	}
+
+	btc200.Nonce += 1
+	if err := btc200.CheckProofOfWork(PowLimitBitcoin); err != ErrHighHash {
+		t.Errorf("Expected ErrHighHash for modified header, got %v", err)
+	}
+
+	btc200.NBits = 0x1e00ffff
+	if err := btc200.CheckProofOfWork(PowLimitBitcoin); err != ErrInvalidTarget {
+		t.Errorf("Expected ErrInvalidTarget for target above pow limit, got %v", err)
+	}
+
+	btc200.NBits = 0x1d80ffff
+	if err := btc200.CheckProofOfWork(PowLimitBitcoin); err != ErrInvalidTarget {
+		t.Errorf("Expected ErrInvalidTarget for negative target, got %v", err)
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	if block.NBits != 440839772 {
+		t.Fatalf("Incorrect NBits. Expected %d, got %d", 440839772, block.NBits)
+	}
+
+	// DigiByte's groestl blocks are not hashed with sha256d
+	if err := block.CheckProofOfWork(PowLimitDigiByte); !errors.Is(err, ErrHighHash) {
+		t.Errorf("Expected ErrHighHash for non-sha256d block, got %v", err)
+	}
+
+	difficulty := block.Difficulty(PowLimitBitcoin)
+	if difficulty < 237361.0 || difficulty > 237362.0 {
+		t.Errorf("Incorrect difficulty. Expected about %f, got %f", 237361.4, difficulty)
+	}
+
+	if block.Difficulty(PowLimitDigiByte) <= block.Difficulty(PowLimitBitcoin) {
+		t.Error("Difficulty should be higher relative to an easier pow limit")
+	}
+}
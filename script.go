@@ -5,12 +5,19 @@ import (
 )
 
 // Bitcoin script type backed by a byte array
-// The string function is particularly helpful for working
-// with the stack and getting it into a string representation
+// String returns the script as hex, while Disassemble returns
+// the opcodes and pushes it is made of in bitcoin's asm format
 type Script []byte
 
 func (script Script) IsOpReturn() bool {
-	return script[0] == 0x6a
+	return len(script) > 0 && script[0] == 0x6a
+}
+
+// Returns true if the script can never be spent, either because it starts
+// with OP_RETURN or because it is longer than bitcoin allows scripts to
+// be, as CScript::IsUnspendable in bitcoin's script.h
+func (script Script) IsUnspendable() bool {
+	return script.IsOpReturn() || len(script) > maxScriptSize
 }
 
 func (script Script) IsP2PK() bool {
@@ -18,19 +25,12 @@ func (script Script) IsP2PK() bool {
 		return false
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-	pushLength := uint64(scriptReader.ReadByte())
-	scriptReader.ReadBytes(pushLength)
-	firstOp := scriptReader.ReadByte()
-
-	if firstOp != 0xac {
+	ops, err := script.Ops()
+	if err != nil || len(ops) != 2 {
 		return false
 	}
 
-	return true
+	return len(ops[0].Data) == len(script)-2 && ops[1].Opcode == OP_CHECKSIG
 }
 
 func (script Script) P2PKHash160() ([]byte, error) {
@@ -42,45 +42,25 @@ func (script Script) P2PKHash160() ([]byte, error) {
 		return nil, errors.New("Invalid script for P2PK address")
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	pushLength := uint64(scriptReader.ReadByte())
-	pubkey := scriptReader.ReadBytes(pushLength)
-	return Hash160(Sha256(pubkey)), nil
+	ops, _ := script.Ops()
+	return Hash160(ops[0].Data), nil
 }
 
 func (script Script) IsP2PKH() bool {
-
 	if len(script) != 25 {
 		return false
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	firstOp := scriptReader.ReadByte()
-	secondOp := scriptReader.ReadByte()
-
-	if firstOp != 0x76 && secondOp != 0xa9 {
-		return false
-	}
-
-	pushLength := uint64(scriptReader.ReadByte())
-	scriptReader.ReadBytes(pushLength)
-
-	thirdOp := scriptReader.ReadByte()
-	fourthOp := scriptReader.ReadByte()
-
-	if thirdOp != 0x88 && fourthOp != 0xac {
+	ops, err := script.Ops()
+	if err != nil || len(ops) != 5 {
 		return false
 	}
 
-	return true
+	return ops[0].Opcode == OP_DUP &&
+		ops[1].Opcode == OP_HASH160 &&
+		len(ops[2].Data) == 20 &&
+		ops[3].Opcode == OP_EQUALVERIFY &&
+		ops[4].Opcode == OP_CHECKSIG
 }
 
 func (script Script) P2PKHHash160() ([]byte, error) {
@@ -92,14 +72,8 @@ func (script Script) P2PKHHash160() ([]byte, error) {
 		return nil, errors.New("Invalid script for P2PKH address")
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	scriptReader.ReadBytes(2)
-	pushLength := uint64(scriptReader.ReadByte())
-	return scriptReader.ReadBytes(pushLength), nil
+	ops, _ := script.Ops()
+	return ops[2].Data, nil
 }
 
 func (script Script) IsP2SH() bool {
@@ -107,27 +81,14 @@ func (script Script) IsP2SH() bool {
 		return false
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	firstOp := scriptReader.ReadByte()
-	if firstOp != 0xa9 {
-		return false
-	}
-
-	pushLength := uint64(scriptReader.ReadByte())
-	scriptReader.ReadBytes(pushLength)
-
-	secondOp := scriptReader.ReadByte()
-
-	if secondOp != 0x87 {
+	ops, err := script.Ops()
+	if err != nil || len(ops) != 3 {
 		return false
 	}
 
-	return true
-
+	return ops[0].Opcode == OP_HASH160 &&
+		len(ops[1].Data) == 20 &&
+		ops[2].Opcode == OP_EQUAL
 }
 
 func (script Script) P2SHHash160() ([]byte, error) {
@@ -139,33 +100,20 @@ func (script Script) P2SHHash160() ([]byte, error) {
 		return nil, errors.New("Invalid script for P2SH address")
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	scriptReader.ReadByte()
-	pushLength := uint64(scriptReader.ReadByte())
-	return scriptReader.ReadBytes(pushLength), nil
+	ops, _ := script.Ops()
+	return ops[1].Data, nil
 }
 
+// Returns true if the script is a witness program: a version 0 program of
+// 20 or 32 bytes, or a program of 2 to 40 bytes for versions 1 to 16, such
+// as a version 1 taproot output
 func (script Script) IsWitnessScript() bool {
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	witnessVersion := scriptReader.ReadByte()
-	if witnessVersion != 0x00 {
-		return false
-	}
-
-	pushLengthByte := scriptReader.ReadByte()
-	if pushLengthByte != 0x20 && pushLengthByte != 0x14 {
+	version, program, ok := script.witnessProgram()
+	if !ok {
 		return false
 	}
 
-	return true
+	return version != 0 || len(program) == 20 || len(program) == 32
 }
 
 func (script Script) WitnessVersion() (byte, error) {
@@ -173,12 +121,8 @@ func (script Script) WitnessVersion() (byte, error) {
 		return 0xFF, errors.New("Invalid witness program")
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	return scriptReader.ReadByte(), nil
+	version, _, _ := script.witnessProgram()
+	return version, nil
 }
 
 func (script Script) WitnessProgram() ([]byte, error) {
@@ -186,12 +130,6 @@ func (script Script) WitnessProgram() ([]byte, error) {
 		return nil, errors.New("Invalid witness program")
 	}
 
-	scriptReader := ByteReader{
-		Bytes:  script,
-		Cursor: 0,
-	}
-
-	scriptReader.ReadByte()
-	pushLength := uint64(scriptReader.ReadByte())
-	return scriptReader.ReadBytes(pushLength), nil
+	_, program, _ := script.witnessProgram()
+	return program, nil
 }
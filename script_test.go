@@ -59,3 +59,58 @@ func TestHash160(t *testing.T) {
 		t.Errorf("Returned incorrect hash160. Expected %s, got %s", "8262506edc566112199930149185b7116b74e22e", ToHexString(hash160))
 	}
 }
+
+func TestScriptP2PK(t *testing.T) {
+	var script Script
+	script, _ = hex.DecodeString("21031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126faac")
+
+	if !script.IsP2PK() {
+		t.Error("Incorrectly declared script as non-P2PK")
+	}
+
+	hash160, err := script.P2PKHash160()
+	if err != nil {
+		t.Errorf("Error reading hash160: %s", err)
+	}
+	if ToHexString(hash160) != "8262506edc566112199930149185b7116b74e22e" {
+		t.Errorf("Returned incorrect hash160. Expected %s, got %s", "8262506edc566112199930149185b7116b74e22e", ToHexString(hash160))
+	}
+}
+
+func TestScriptWitness(t *testing.T) {
+	var script Script
+	script, _ = hex.DecodeString("0014751e76e8199196d454941c45d1b3a323f1433bd6")
+
+	if !script.IsWitnessScript() {
+		t.Error("Incorrectly declared script as non-witness")
+	}
+
+	program, err := script.WitnessProgram()
+	if err != nil {
+		t.Errorf("Error reading witness program: %s", err)
+	}
+	if ToHexString(program) != "751e76e8199196d454941c45d1b3a323f1433bd6" {
+		t.Errorf("Returned incorrect witness program. Expected %s, got %s", "751e76e8199196d454941c45d1b3a323f1433bd6", ToHexString(program))
+	}
+}
+
+func TestScriptNonStandard(t *testing.T) {
+	var tests = []string{
+		"",
+		"76",
+		"76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ad",
+		"76a94c13bdb2b538e6b07e93d6bafcef4bec9dc936818a88ac",
+		"a9144aef67ed61d391d6f3d9903ead92386c1efc992588",
+		"21031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126faad",
+		"0014751e76e8199196d454941c45d1b3a323f1433bd600",
+		"0015751e76e8199196d454941c45d1b3a323f1433bd6",
+	}
+
+	for _, test := range tests {
+		var script Script
+		script, _ = hex.DecodeString(test)
+		if script.IsP2PK() || script.IsP2PKH() || script.IsP2SH() || script.IsWitnessScript() {
+			t.Errorf("Incorrectly declared %s as a standard script", test)
+		}
+	}
+}
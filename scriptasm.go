@@ -0,0 +1,283 @@
+package blockutils
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Returned when asm text can not be assembled into a script
+var ErrInvalidScriptASM = errors.New("Invalid script asm")
+
+// The names of the sighash types, as shown after signatures in the asm of
+// a scriptSig
+var sigHashTypeNames = map[byte]string{
+	0x01: "ALL",
+	0x02: "NONE",
+	0x03: "SINGLE",
+	0x81: "ALL|ANYONECANPAY",
+	0x82: "NONE|ANYONECANPAY",
+	0x83: "SINGLE|ANYONECANPAY",
+}
+
+// Returns the script in bitcoin's asm format, as shown by decodescript,
+// such as "OP_DUP OP_HASH160 <hash> OP_EQUALVERIFY OP_CHECKSIG".
+//
+// Pushes of up to 4 bytes are shown as numbers and longer pushes as hex.
+// A push which runs past the end of the script ends the output with
+// "[error]"
+func (script Script) Disassemble() string {
+	return script.disassemble(false)
+}
+
+// Returns the script in bitcoin's asm format, additionally showing the
+// sighash type of DER signatures after their hex, such as
+// "<signature>[ALL] <pubkey>". This matches the scriptSig asm shown by
+// getrawtransaction
+func (script Script) DisassembleWithSigHash() string {
+	return script.disassemble(true)
+}
+
+// As ScriptToAsmStr in bitcoin's core_write.cpp
+func (script Script) disassemble(decodeSigHash bool) string {
+	var asm strings.Builder
+	tokenizer := NewScriptTokenizer(script)
+	for tokenizer.Next() {
+		if asm.Len() > 0 {
+			asm.WriteString(" ")
+		}
+
+		op := tokenizer.Op()
+		if op.Opcode > OP_PUSHDATA4 {
+			asm.WriteString(OpcodeName(op.Opcode))
+			continue
+		}
+
+		if n, err := ParseScriptNum(op.Data, DefaultScriptNumLength, false); err == nil {
+			asm.WriteString(n.String())
+			continue
+		}
+
+		data := op.Data
+		suffix := ""
+		if decodeSigHash && !script.IsUnspendable() && isStrictSignature(data) {
+			suffix = "[" + sigHashTypeNames[data[len(data)-1]] + "]"
+			data = data[:len(data)-1]
+		}
+		asm.WriteString(ToHexString(data))
+		asm.WriteString(suffix)
+	}
+
+	if tokenizer.Err() != nil {
+		if asm.Len() > 0 {
+			asm.WriteString(" ")
+		}
+		asm.WriteString("[error]")
+	}
+	return asm.String()
+}
+
+// The opcodes ParseScriptASM accepts by name, with or without their OP_
+// prefix. Push opcodes are written as numbers or data instead
+var opcodesByName = func() map[string]byte {
+	names := map[string]byte{
+		"OP_0":       OP_0,
+		"OP_FALSE":   OP_FALSE,
+		"OP_1NEGATE": OP_1NEGATE,
+		"OP_TRUE":    OP_TRUE,
+		"OP_NOP2":    OP_NOP2,
+		"OP_NOP3":    OP_NOP3,
+	}
+	for n := 1; n <= 16; n++ {
+		names[fmt.Sprintf("OP_%d", n)] = byte(OP_1 + n - 1)
+	}
+	for opcode, name := range opcodeNames {
+		if opcode > OP_PUSHDATA4 && strings.HasPrefix(name, "OP_") {
+			names[name] = opcode
+		}
+	}
+
+	for name, opcode := range names {
+		names[strings.TrimPrefix(name, "OP_")] = opcode
+	}
+	return names
+}()
+
+// Builds a script from asm text, as ParseScript in bitcoin's core_read.cpp
+// does for its script test vectors. Tokens are separated by whitespace and
+// may be:
+//
+//   - an opcode name, with or without its OP_ prefix, such as OP_DUP or DUP
+//   - a decimal number, pushed as a script number using the smallest
+//     opcode able to push it
+//   - 0x followed by hex, inserted into the script as raw bytes
+//   - a string in single quotes, pushed as data
+//   - hex, as in the output of Disassemble, pushed as data. A sighash type
+//     such as [ALL] may follow, and is appended to the data
+//
+// Tokens made only of digits are always read as numbers, so the asm of a
+// data push which happens to be all digits does not round trip
+func ParseScriptASM(asm string) (Script, error) {
+	script := Script{}
+	for _, token := range strings.Fields(asm) {
+		var err error
+		script, err = appendASMToken(script, token)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse %q: %w", token, err)
+		}
+	}
+	return script, nil
+}
+
+func appendASMToken(script Script, token string) (Script, error) {
+	if isDecimal(token) {
+		n, err := strconv.ParseInt(token, 10, 64)
+		if err != nil || n > 0xffffffff || n < -0xffffffff {
+			return nil, fmt.Errorf("Number out of range: %w", ErrInvalidScriptASM)
+		}
+		return appendScriptNum(script, n), nil
+	}
+
+	if strings.HasPrefix(token, "0x") {
+		raw, err := hex.DecodeString(token[2:])
+		if err != nil || len(raw) == 0 {
+			return nil, fmt.Errorf("Invalid hex: %w", ErrInvalidScriptASM)
+		}
+		return append(script, raw...), nil
+	}
+
+	if len(token) >= 2 && token[0] == '\'' && token[len(token)-1] == '\'' {
+		return appendPushData(script, []byte(token[1:len(token)-1])), nil
+	}
+
+	if opcode, ok := opcodesByName[token]; ok {
+		return append(script, opcode), nil
+	}
+
+	data := token
+	suffix := ""
+	if i := strings.IndexByte(token, '['); i > 0 && strings.HasSuffix(token, "]") {
+		data = token[:i]
+		suffix = token[i+1 : len(token)-1]
+	}
+
+	push, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown opcode: %w", ErrInvalidScriptASM)
+	}
+
+	if suffix != "" {
+		sighash, ok := sigHashTypesByName(suffix)
+		if !ok {
+			return nil, fmt.Errorf("Unknown sighash type: %w", ErrInvalidScriptASM)
+		}
+		push = append(push, sighash)
+	}
+	return appendPushData(script, push), nil
+}
+
+// Returns true if token is a decimal number, optionally negative
+func isDecimal(token string) bool {
+	digits := strings.TrimPrefix(token, "-")
+	if digits == "" {
+		return false
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func sigHashTypesByName(name string) (byte, bool) {
+	for sighash, sighashName := range sigHashTypeNames {
+		if sighashName == name {
+			return sighash, true
+		}
+	}
+	return 0, false
+}
+
+// Appends a push of data using the smallest possible encoding, as required
+// by bitcoin's MINIMALDATA rule: empty data and single bytes from 1 to 16
+// or 0x81 use OP_0, OP_1 to OP_16 or OP_1NEGATE, and other data the
+// shortest push opcode able to hold it
+func appendPushData(script Script, data []byte) Script {
+	switch {
+	case len(data) == 0:
+		return append(script, OP_0)
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		return append(script, OP_1+data[0]-1)
+	case len(data) == 1 && data[0] == 0x81:
+		return append(script, OP_1NEGATE)
+	}
+
+	writer := ByteWriter{
+		Bytes: script,
+	}
+	switch {
+	case len(data) < OP_PUSHDATA1:
+		writer.WriteBytes([]byte{byte(len(data))})
+	case len(data) <= 0xff:
+		writer.WriteBytes([]byte{OP_PUSHDATA1, byte(len(data))})
+	case len(data) <= 0xffff:
+		writer.WriteBytes([]byte{OP_PUSHDATA2})
+		writer.WriteUint16(uint16(len(data)))
+	default:
+		writer.WriteBytes([]byte{OP_PUSHDATA4})
+		writer.WriteUint32(uint32(len(data)))
+	}
+	writer.WriteBytes(data)
+	return writer.Bytes
+}
+
+// Appends a push of n as a script number, using OP_0, OP_1NEGATE or OP_1
+// to OP_16 for small numbers
+func appendScriptNum(script Script, n int64) Script {
+	return appendPushData(script, ScriptNum(n).Bytes())
+}
+
+// Returns true if sig is a strictly DER encoded signature followed by a
+// defined sighash type, as CheckSignatureEncoding in bitcoin's
+// interpreter.cpp with SCRIPT_VERIFY_STRICTENC
+func isStrictSignature(sig []byte) bool {
+	if _, ok := sigHashTypeNames[sig[len(sig)-1]]; !ok {
+		return false
+	}
+
+	// 0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S] [sighash]
+	if len(sig) < 9 || len(sig) > 73 {
+		return false
+	}
+	if sig[0] != 0x30 || int(sig[1]) != len(sig)-3 {
+		return false
+	}
+
+	lenR := int(sig[3])
+	if 5+lenR >= len(sig) {
+		return false
+	}
+	lenS := int(sig[5+lenR])
+	if lenR+lenS+7 != len(sig) {
+		return false
+	}
+
+	// R and S must be positive integers without unnecessary padding
+	if sig[2] != 0x02 || lenR == 0 || sig[4]&0x80 != 0 {
+		return false
+	}
+	if lenR > 1 && sig[4] == 0x00 && sig[5]&0x80 == 0 {
+		return false
+	}
+	if sig[lenR+4] != 0x02 || lenS == 0 || sig[lenR+6]&0x80 != 0 {
+		return false
+	}
+	if lenS > 1 && sig[lenR+6] == 0x00 && sig[lenR+7]&0x80 == 0 {
+		return false
+	}
+	return true
+}
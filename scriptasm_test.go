@@ -0,0 +1,174 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+type testpairasm struct {
+	input  string
+	output string
+}
+
+func TestScriptDisassemble(t *testing.T) {
+	var tests = []testpairasm{
+		{"", ""},
+		{"76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac", "OP_DUP OP_HASH160 bdb2b538e6b07e93d6bafcef4bec9dc936818a19 OP_EQUALVERIFY OP_CHECKSIG"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", "OP_HASH160 4aef67ed61d391d6f3d9903ead92386c1efc9925 OP_EQUAL"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", "0 751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"5121031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa51ae", "1 031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa 1 OP_CHECKMULTISIG"},
+		{"4f00605a", "-1 0 16 10"},
+		{"0101020080038000800481000000", "1 0 -128 129"},
+		{"4c0412345678", "2018915346"},
+		{"4d0500aabbccddee", "aabbccddee"},
+		{"4e05000000aabbccddee", "aabbccddee"},
+		{"b1b2b9bababbff", "OP_CHECKLOCKTIMEVERIFY OP_CHECKSEQUENCEVERIFY OP_NOP10 OP_CHECKSIGADD OP_CHECKSIGADD OP_UNKNOWN OP_INVALIDOPCODE"},
+		{"6a0401020304", "OP_RETURN 67305985"},
+		{"76a914bdb2", "OP_DUP OP_HASH160 [error]"},
+		{"4c", "[error]"},
+		{"4d01", "[error]"},
+		{"4e01000000", "[error]"},
+	}
+
+	for _, pair := range tests {
+		script, _ := hex.DecodeString(pair.input)
+		asm := Script(script).Disassemble()
+		if asm != pair.output {
+			t.Errorf("Incorrect asm for %s. Expected %s, got %s", pair.input, pair.output, asm)
+		}
+	}
+}
+
+func TestScriptDisassembleWithSigHash(t *testing.T) {
+	tx, err := NewTransactionFromHexString(digibytetx)
+	if err != nil {
+		t.Fatalf("Could not parse tx hex; %s", err)
+	}
+
+	expected := "3045022100eb4671f9bbcbcc937855ef8aad774ff81cd4aedc65f79fedf2a9c88c9cd566c6022034039dd992ab0be0db95a1d7b615bb2c39e7b16515c74c9f021dd39ac0ffe213[ALL] 02f24f8135e2f62f81d6c4ff172fd2681a3e03cf7485510a2871ca2c41b5aa9733"
+	asm := tx.Vin[0].Script.DisassembleWithSigHash()
+	if asm != expected {
+		t.Errorf("Incorrect scriptSig asm. Expected %s, got %s", expected, asm)
+	}
+
+	// Without sighash decoding the signature is shown as is
+	expected = "3045022100eb4671f9bbcbcc937855ef8aad774ff81cd4aedc65f79fedf2a9c88c9cd566c6022034039dd992ab0be0db95a1d7b615bb2c39e7b16515c74c9f021dd39ac0ffe21301 02f24f8135e2f62f81d6c4ff172fd2681a3e03cf7485510a2871ca2c41b5aa9733"
+	asm = tx.Vin[0].Script.Disassemble()
+	if asm != expected {
+		t.Errorf("Incorrect scriptSig asm. Expected %s, got %s", expected, asm)
+	}
+
+	// Changing the sighash type to an undefined one leaves it as is
+	script := append(Script{}, tx.Vin[0].Script...)
+	script[72] = 0x04
+	if asm := script.DisassembleWithSigHash(); asm != script.Disassemble() {
+		t.Errorf("Incorrect scriptSig asm for undefined sighash type. Got %s", asm)
+	}
+
+	// Sighash types are not decoded in scripts too long to be spent
+	script = append(Script{}, tx.Vin[0].Script...)
+	for len(script) <= maxScriptSize {
+		script = append(script, OP_NOP)
+	}
+	if !script.IsUnspendable() {
+		t.Error("Incorrectly declared oversized script as spendable")
+	}
+	if asm := script.DisassembleWithSigHash(); asm != script.Disassemble() {
+		t.Errorf("Incorrect asm for oversized script. Expected %s..., got %s...", script.Disassemble()[:80], asm[:80])
+	}
+}
+
+func TestParseScriptASM(t *testing.T) {
+	var tests = []testpairasm{
+		{"", ""},
+		{"OP_DUP OP_HASH160 bdb2b538e6b07e93d6bafcef4bec9dc936818a19 OP_EQUALVERIFY OP_CHECKSIG", "76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac"},
+		{"DUP HASH160 0x14 0xbdb2b538e6b07e93d6bafcef4bec9dc936818a19 EQUALVERIFY CHECKSIG", "76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac"},
+		{"0 -1 1 16 17 -17 127 128 -128 255 256 -255 4294967295 -4294967295", "004f51600111019101" + "7f028000028080" + "02ff00020001" + "02ff8005ffffffff0005ffffffff80"},
+		{"OP_0 OP_FALSE OP_TRUE OP_1NEGATE OP_16 OP_NOP2 NOP3 CHECKLOCKTIMEVERIFY CHECKSIGADD", "0000514f60b1b2b1ba"},
+		{"'' 'a' 'Az'", "00016102417a"},
+		{"0x4c 0x01 0x07", "4c0107"},
+		{"aabbccddee ff 0a", "05aabbccddee01ff5a"},
+	}
+
+	for _, pair := range tests {
+		script, err := ParseScriptASM(pair.input)
+		if err != nil {
+			t.Errorf("Could not parse %s: %s", pair.input, err)
+			continue
+		}
+		if script.String() != pair.output {
+			t.Errorf("Incorrect script for %s. Expected %s, got %s", pair.input, pair.output, script)
+		}
+	}
+}
+
+func TestParseScriptASMPushData(t *testing.T) {
+	var tests = []testpairuint64{
+		{[]byte{0x4b}, 75},
+		{[]byte{0x4c, 0x4c}, 76},
+		{[]byte{0x4c, 0xff}, 255},
+		{[]byte{0x4d, 0x00, 0x01}, 256},
+		{[]byte{0x4d, 0xff, 0xff}, 65535},
+		{[]byte{0x4e, 0x00, 0x00, 0x01, 0x00}, 65536},
+	}
+
+	for _, pair := range tests {
+		data := bytes.Repeat([]byte{0xaa}, int(pair.output))
+		script, err := ParseScriptASM(hex.EncodeToString(data))
+		if err != nil {
+			t.Errorf("Could not parse %d byte push: %s", pair.output, err)
+			continue
+		}
+		if !bytes.Equal(script[:len(pair.input)], pair.input) || !bytes.Equal(script[len(pair.input):], data) {
+			t.Errorf("Incorrect push of %d bytes. Expected prefix %x, got %x", pair.output, pair.input, script[:len(pair.input)])
+		}
+	}
+}
+
+func TestParseScriptASMRoundTrip(t *testing.T) {
+	tx, err := NewTransactionFromHexString(digibytetx)
+	if err != nil {
+		t.Fatalf("Could not parse tx hex; %s", err)
+	}
+
+	scripts := []Script{tx.Vin[0].Script, tx.Vin[1].Script, tx.Vout[0].Script, tx.Vout[1].Script}
+	for _, script := range scripts {
+		for _, asm := range []string{script.Disassemble(), script.DisassembleWithSigHash()} {
+			parsed, err := ParseScriptASM(asm)
+			if err != nil {
+				t.Errorf("Could not parse %s: %s", asm, err)
+				continue
+			}
+			if !bytes.Equal(parsed, script) {
+				t.Errorf("Incorrect script for %s. Expected %s, got %s", asm, script, parsed)
+			}
+		}
+	}
+}
+
+func TestParseScriptASMErrors(t *testing.T) {
+	var tests = []string{
+		"OP_NOTANOPCODE",
+		"OP_PUSHDATA1",
+		"PUSHDATA2",
+		"OP_UNKNOWN",
+		"4294967296",
+		"-4294967296",
+		"0x",
+		"0xabc",
+		"abc",
+		"'unterminated",
+		"aabbccddee[BAD]",
+		"[error]",
+		"-",
+	}
+
+	for _, asm := range tests {
+		_, err := ParseScriptASM(asm)
+		if !errors.Is(err, ErrInvalidScriptASM) {
+			t.Errorf("Incorrect error for %s. Expected %s, got %v", asm, ErrInvalidScriptASM, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package blockutils
+
+import (
+	"errors"
+	"strconv"
+)
+
+// A number as used by script, as CScriptNum in bitcoin's script.h.
+// Script numbers are little endian, with the top bit of the last byte
+// holding the sign, and zero is the empty byte string
+type ScriptNum int64
+
+// Arithmetic opcodes only accept numbers of up to 4 bytes. Some, such as
+// OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY, accept 5 bytes
+const (
+	DefaultScriptNumLength  = 4
+	LockTimeScriptNumLength = 5
+)
+
+// Script numbers longer than this do not fit in an int64
+const maxScriptNumLength = 8
+
+// Returned when script number data is longer than allowed
+var ErrScriptNumTooLong = errors.New("Script number too long")
+
+// Returned when script number data has unnecessary bytes
+var ErrScriptNumNotMinimal = errors.New("Script number not minimally encoded")
+
+// Returned when reading a number from an operation which does not push one
+var ErrNotScriptNum = errors.New("Operation does not push a number")
+
+// Decodes a script number from up to maxLength bytes of data. When
+// requireMinimal is set, data with unnecessary bytes is rejected, as
+// bitcoin does for scripts verified with SCRIPT_VERIFY_MINIMALDATA
+func ParseScriptNum(data []byte, maxLength int, requireMinimal bool) (ScriptNum, error) {
+	if len(data) > maxLength || len(data) > maxScriptNumLength {
+		return 0, ErrScriptNumTooLong
+	}
+	if requireMinimal && !isMinimalScriptNum(data) {
+		return 0, ErrScriptNumNotMinimal
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	result := uint64(0)
+	for i, b := range data {
+		result |= uint64(b) << (8 * uint(i))
+	}
+
+	// The top bit of the last byte is the sign
+	last := len(data) - 1
+	if data[last]&0x80 != 0 {
+		return -ScriptNum(result &^ (uint64(0x80) << (8 * uint(last)))), nil
+	}
+	return ScriptNum(result), nil
+}
+
+// Returns the minimal encoding of the number, as CScriptNum::serialize in
+// bitcoin's script.h
+func (n ScriptNum) Bytes() []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	negative := n < 0
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+
+	result := []byte{}
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+
+	// If the top bit of the last byte is already in use, add a byte to
+	// hold the sign
+	if result[len(result)-1]&0x80 != 0 {
+		if negative {
+			result = append(result, 0x80)
+		} else {
+			result = append(result, 0x00)
+		}
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+func (n ScriptNum) String() string {
+	return strconv.FormatInt(int64(n), 10)
+}
+
+// Returns the number an operation puts on the stack, whether pushed as
+// data or with OP_1NEGATE, OP_0 or OP_1 to OP_16. When requireMinimal is
+// set, the push and the number must both be minimally encoded
+func (op ScriptOp) ScriptNum(maxLength int, requireMinimal bool) (ScriptNum, error) {
+	if op.Opcode == OP_1NEGATE {
+		return -1, nil
+	}
+	if n, ok := smallIntValue(op.Opcode); ok {
+		return ScriptNum(n), nil
+	}
+
+	if !op.IsPush() {
+		return 0, ErrNotScriptNum
+	}
+	if requireMinimal && !op.IsMinimalPush() {
+		return 0, ErrScriptNumNotMinimal
+	}
+	return ParseScriptNum(op.Data, maxLength, requireMinimal)
+}
+
+// Returns true if data is a script number without unnecessary bytes. Only
+// the last byte may hold just the sign, and only when the byte before it
+// needs its top bit for the magnitude
+func isMinimalScriptNum(data []byte) bool {
+	if len(data) == 0 {
+		return true
+	}
+
+	last := len(data) - 1
+	if data[last]&0x7f == 0 {
+		if len(data) == 1 || data[last-1]&0x80 == 0 {
+			return false
+		}
+	}
+	return true
+}
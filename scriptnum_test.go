@@ -0,0 +1,118 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"testing"
+)
+
+type testpairscriptnum struct {
+	input  string
+	output ScriptNum
+}
+
+func TestScriptNum(t *testing.T) {
+	var tests = []testpairscriptnum{
+		{"", 0},
+		{"01", 1},
+		{"81", -1},
+		{"7f", 127},
+		{"ff", -127},
+		{"8000", 128},
+		{"8080", -128},
+		{"ff00", 255},
+		{"0001", 256},
+		{"ffff7f", 8388607},
+		{"00008000", 8388608},
+		{"00008080", -8388608},
+		{"ffffff7f", 2147483647},
+		{"ffffffff", -2147483647},
+		{"0000008000", 2147483648},
+		{"ffffffff00", 4294967295},
+		{"ffffffffffffff7f", math.MaxInt64},
+		{"ffffffffffffffff", -math.MaxInt64},
+	}
+
+	for _, pair := range tests {
+		data, _ := hex.DecodeString(pair.input)
+		n, err := ParseScriptNum(data, 8, true)
+		if err != nil {
+			t.Errorf("Could not parse %s: %s", pair.input, err)
+		}
+		if n != pair.output {
+			t.Errorf("Incorrect script number for %s. Expected %d, got %d", pair.input, pair.output, n)
+		}
+
+		if !bytes.Equal(pair.output.Bytes(), data) {
+			t.Errorf("Incorrect encoding of %d. Expected %s, got %x", pair.output, pair.input, pair.output.Bytes())
+		}
+	}
+
+	if hex.EncodeToString(ScriptNum(math.MinInt64).Bytes()) != "000000000000008080" {
+		t.Errorf("Incorrect encoding of %d. Got %x", ScriptNum(math.MinInt64), ScriptNum(math.MinInt64).Bytes())
+	}
+}
+
+func TestScriptNumErrors(t *testing.T) {
+	var tests = []struct {
+		input     string
+		maxLength int
+		minimal   bool
+		output    ScriptNum
+		err       error
+	}{
+		// Negative zero and padding are accepted unless minimal is required
+		{"80", 4, false, 0, nil},
+		{"80", 4, true, 0, ErrScriptNumNotMinimal},
+		{"0100", 4, false, 1, nil},
+		{"0100", 4, true, 0, ErrScriptNumNotMinimal},
+		{"000080", 4, false, 0, nil},
+		{"0000000000", 4, false, 0, ErrScriptNumTooLong},
+		{"0000008000", 4, true, 0, ErrScriptNumTooLong},
+		{"0000008000", LockTimeScriptNumLength, true, 2147483648, nil},
+		{"000000000000000001", 9, false, 0, ErrScriptNumTooLong},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.input)
+		n, err := ParseScriptNum(data, test.maxLength, test.minimal)
+		if !errors.Is(err, test.err) || n != test.output {
+			t.Errorf("Incorrect result for %s. Expected %d %v, got %d %v", test.input, test.output, test.err, n, err)
+		}
+	}
+}
+
+func TestScriptOpScriptNum(t *testing.T) {
+	script, _ := hex.DecodeString("004f51600111020100" + "4c0111" + "76")
+	ops, err := Script(script).Ops()
+	if err != nil {
+		t.Fatalf("Could not read ops: %s", err)
+	}
+
+	var tests = []struct {
+		op      ScriptOp
+		minimal bool
+		output  ScriptNum
+		err     error
+	}{
+		{ops[0], true, 0, nil},
+		{ops[1], true, -1, nil},
+		{ops[2], true, 1, nil},
+		{ops[3], true, 16, nil},
+		{ops[4], true, 17, nil},
+		{ops[5], true, 0, ErrScriptNumNotMinimal},
+		{ops[5], false, 1, nil},
+		{ops[6], true, 0, ErrScriptNumNotMinimal},
+		{ops[6], false, 17, nil},
+		{ops[7], false, 0, ErrNotScriptNum},
+	}
+
+	for i, test := range tests {
+		n, err := test.op.ScriptNum(DefaultScriptNumLength, test.minimal)
+		if !errors.Is(err, test.err) || n != test.output {
+			t.Errorf("Incorrect result for test %d. Expected %d %v, got %d %v", i, test.output, test.err, n, err)
+		}
+	}
+}
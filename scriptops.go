@@ -0,0 +1,150 @@
+package blockutils
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Returned when a push opcode claims more data than is left in the script
+var ErrTruncatedPush = errors.New("Push extends past the end of the script")
+
+// A single operation of a script, at Offset bytes into it. Data holds the
+// bytes pushed by push opcodes, and is nil otherwise
+type ScriptOp struct {
+	Opcode byte
+	Data   []byte
+	Offset int
+}
+
+// Returns true if the operation pushes data, including the empty push of
+// OP_0. The small integer opcodes OP_1NEGATE and OP_1 to OP_16 are not
+// data pushes
+func (op ScriptOp) IsPush() bool {
+	return op.Opcode <= OP_PUSHDATA4
+}
+
+// Returns true if a push operation uses the smallest possible encoding
+// for its data, as CheckMinimalPush in bitcoin's interpreter.cpp
+func (op ScriptOp) IsMinimalPush() bool {
+	switch {
+	case len(op.Data) == 0:
+		return op.Opcode == OP_0
+	case len(op.Data) == 1 && op.Data[0] >= 1 && op.Data[0] <= 16:
+		return false // Should have used OP_1 to OP_16
+	case len(op.Data) == 1 && op.Data[0] == 0x81:
+		return false // Should have used OP_1NEGATE
+	case len(op.Data) < OP_PUSHDATA1:
+		return int(op.Opcode) == len(op.Data)
+	case len(op.Data) <= 0xff:
+		return op.Opcode == OP_PUSHDATA1
+	case len(op.Data) <= 0xffff:
+		return op.Opcode == OP_PUSHDATA2
+	}
+	return true
+}
+
+// A ScriptTokenizer walks the operations of a script one at a time, as
+// GetScriptOp in bitcoin's script.cpp:
+//
+//	tokenizer := NewScriptTokenizer(script)
+//	for tokenizer.Next() {
+//		op := tokenizer.Op()
+//		...
+//	}
+//	if tokenizer.Err() != nil {
+//		...
+//	}
+//
+// A push which runs past the end of the script stops the walk, with Err
+// returning a *ReadError wrapping ErrTruncatedPush
+type ScriptTokenizer struct {
+	script Script
+	offset int
+	op     ScriptOp
+	err    error
+}
+
+// Returns a ScriptTokenizer positioned before the first operation of script
+func NewScriptTokenizer(script Script) *ScriptTokenizer {
+	return &ScriptTokenizer{
+		script: script,
+	}
+}
+
+// Moves to the next operation, returning false once the end of the script
+// is reached or an error occurs
+func (t *ScriptTokenizer) Next() bool {
+	if t.err != nil || t.offset >= len(t.script) {
+		return false
+	}
+
+	t.op, t.offset, t.err = readScriptOp(t.script, t.offset)
+	return t.err == nil
+}
+
+// Returns the current operation
+func (t *ScriptTokenizer) Op() ScriptOp {
+	return t.op
+}
+
+// Returns the error which stopped the walk, if any
+func (t *ScriptTokenizer) Err() error {
+	return t.err
+}
+
+// Returns all of the operations of the script. If the script ends with a
+// truncated push, the operations before it are returned along with the
+// error
+func (script Script) Ops() ([]ScriptOp, error) {
+	ops := []ScriptOp{}
+	tokenizer := NewScriptTokenizer(script)
+	for tokenizer.Next() {
+		ops = append(ops, tokenizer.Op())
+	}
+	return ops, tokenizer.Err()
+}
+
+// Reads the operation at offset into script, returning it and the offset
+// of the next one
+func readScriptOp(script Script, offset int) (ScriptOp, int, error) {
+	op := ScriptOp{
+		Opcode: script[offset],
+		Offset: offset,
+	}
+	pos := offset + 1
+	if op.Opcode > OP_PUSHDATA4 {
+		return op, pos, nil
+	}
+
+	remaining := uint64(len(script) - pos)
+	size := uint64(op.Opcode)
+	lengthBytes := 0
+	switch op.Opcode {
+	case OP_PUSHDATA1:
+		lengthBytes = 1
+	case OP_PUSHDATA2:
+		lengthBytes = 2
+	case OP_PUSHDATA4:
+		lengthBytes = 4
+	}
+
+	if remaining < uint64(lengthBytes) {
+		return op, len(script), &ReadError{Offset: uint64(pos), Field: "push length", Err: ErrTruncatedPush}
+	}
+	switch lengthBytes {
+	case 1:
+		size = uint64(script[pos])
+	case 2:
+		size = uint64(binary.LittleEndian.Uint16(script[pos:]))
+	case 4:
+		size = uint64(binary.LittleEndian.Uint32(script[pos:]))
+	}
+	pos += lengthBytes
+
+	if uint64(len(script)-pos) < size {
+		return op, len(script), &ReadError{Offset: uint64(pos), Field: "push data", Err: ErrTruncatedPush}
+	}
+
+	op.Data = script[pos : pos+int(size)]
+	return op, pos + int(size), nil
+}
@@ -0,0 +1,103 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestScriptOps(t *testing.T) {
+	script, _ := hex.DecodeString("0076a94c02aabb4d0300ccddee4e01000000ff51ac03010203")
+	expected := []ScriptOp{
+		{OP_0, []byte{}, 0},
+		{OP_DUP, nil, 1},
+		{OP_HASH160, nil, 2},
+		{OP_PUSHDATA1, []byte{0xaa, 0xbb}, 3},
+		{OP_PUSHDATA2, []byte{0xcc, 0xdd, 0xee}, 7},
+		{OP_PUSHDATA4, []byte{0xff}, 13},
+		{OP_1, nil, 19},
+		{OP_CHECKSIG, nil, 20},
+		{0x03, []byte{0x01, 0x02, 0x03}, 21},
+	}
+
+	ops, err := Script(script).Ops()
+	if err != nil {
+		t.Fatalf("Could not read ops: %s", err)
+	}
+	checkScriptOps(t, ops, expected)
+}
+
+func checkScriptOps(t *testing.T, ops []ScriptOp, expected []ScriptOp) {
+	if len(ops) != len(expected) {
+		t.Fatalf("Incorrect op count. Expected %d, got %d", len(expected), len(ops))
+	}
+
+	for i := range expected {
+		if ops[i].Opcode != expected[i].Opcode || ops[i].Offset != expected[i].Offset || !bytes.Equal(ops[i].Data, expected[i].Data) {
+			t.Errorf("Incorrect op %d. Expected %+v, got %+v", i, expected[i], ops[i])
+		}
+		if ops[i].IsPush() != (expected[i].Data != nil) {
+			t.Errorf("Incorrect IsPush for op %d. Expected %t, got %t", i, expected[i].Data != nil, ops[i].IsPush())
+		}
+	}
+}
+
+func TestScriptOpsTruncated(t *testing.T) {
+	var tests = []struct {
+		script string
+		ops    int
+		offset int
+	}{
+		{"01", 0, 1},
+		{"76a914bdb2", 2, 3},
+		{"4c", 0, 1},
+		{"4c02aa", 0, 2},
+		{"4d01", 0, 1},
+		{"4d0100", 0, 3},
+		{"ac4e000000", 1, 2},
+		{"4effffffffaa", 0, 5},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+		ops, err := Script(script).Ops()
+		if !errors.Is(err, ErrTruncatedPush) {
+			t.Errorf("Incorrect error for %s. Expected %s, got %v", test.script, ErrTruncatedPush, err)
+		}
+
+		var readErr *ReadError
+		if errors.As(err, &readErr) && readErr.Offset != uint64(test.offset) {
+			t.Errorf("Incorrect error offset for %s. Expected %d, got %d", test.script, test.offset, readErr.Offset)
+		}
+
+		if len(ops) != test.ops {
+			t.Errorf("Incorrect op count for %s. Expected %d, got %d", test.script, test.ops, len(ops))
+		}
+	}
+}
+
+func TestScriptTokenizer(t *testing.T) {
+	script, _ := hex.DecodeString("76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac")
+	tokenizer := NewScriptTokenizer(script)
+
+	opcodes := []byte{}
+	for tokenizer.Next() {
+		opcodes = append(opcodes, tokenizer.Op().Opcode)
+	}
+	if tokenizer.Err() != nil {
+		t.Errorf("Unexpected error: %s", tokenizer.Err())
+	}
+
+	if !bytes.Equal(opcodes, []byte{OP_DUP, OP_HASH160, 0x14, OP_EQUALVERIFY, OP_CHECKSIG}) {
+		t.Errorf("Incorrect opcodes. Got %x", opcodes)
+	}
+
+	if tokenizer.Next() {
+		t.Error("Tokenizer continued past the end of the script")
+	}
+
+	if NewScriptTokenizer(nil).Next() {
+		t.Error("Tokenizer returned an op for an empty script")
+	}
+}
@@ -0,0 +1,218 @@
+package blockutils
+
+import "bytes"
+
+// The standard output script templates, named as TxoutType in bitcoin's
+// script/solver.h
+type ScriptType int
+
+const (
+	ScriptNonStandard ScriptType = iota
+	ScriptPubKey
+	ScriptPubKeyHash
+	ScriptScriptHash
+	ScriptMultisig
+	ScriptNullData
+	ScriptWitnessV0KeyHash
+	ScriptWitnessV0ScriptHash
+	ScriptWitnessV1Taproot
+	ScriptAnchor
+	ScriptWitnessUnknown
+)
+
+// Bitcoin allows up to this many public keys in a multisig script
+const maxPubKeysPerMultisig = 20
+
+// The pay to anchor (P2A) output script, a witness v1 program of 0x4e73
+var anchorScript = Script{OP_1, 0x02, 0x4e, 0x73}
+
+var scriptTypeNames = map[ScriptType]string{
+	ScriptNonStandard:         "nonstandard",
+	ScriptPubKey:              "pubkey",
+	ScriptPubKeyHash:          "pubkeyhash",
+	ScriptScriptHash:          "scripthash",
+	ScriptMultisig:            "multisig",
+	ScriptNullData:            "nulldata",
+	ScriptWitnessV0KeyHash:    "witness_v0_keyhash",
+	ScriptWitnessV0ScriptHash: "witness_v0_scripthash",
+	ScriptWitnessV1Taproot:    "witness_v1_taproot",
+	ScriptAnchor:              "anchor",
+	ScriptWitnessUnknown:      "witness_unknown",
+}
+
+// Returns the name of the script type, as shown by decodescript
+func (t ScriptType) String() string {
+	name, ok := scriptTypeNames[t]
+	if !ok {
+		return "nonstandard"
+	}
+	return name
+}
+
+// Returns the standard template the script matches, see Solve
+func (script Script) Type() ScriptType {
+	scriptType, _ := script.Solve()
+	return scriptType
+}
+
+// Returns the standard template the script matches, along with the data
+// needed to spend it, as Solver in bitcoin's script/solver.cpp:
+//
+//   - pubkey: the public key
+//   - pubkeyhash, scripthash: the hash160 of the key or script
+//   - witness_v0_keyhash, witness_v0_scripthash, witness_v1_taproot: the
+//     witness program
+//   - witness_unknown: the witness version as a single byte, followed by
+//     the witness program
+//   - multisig: the number of signatures required as a single byte,
+//     followed by each public key, followed by the number of keys as a
+//     single byte
+//
+// nulldata, anchor and nonstandard scripts have no solutions
+func (script Script) Solve() (ScriptType, [][]byte) {
+	if script.IsP2SH() {
+		return ScriptScriptHash, [][]byte{script[2:22]}
+	}
+
+	if version, program, ok := script.witnessProgram(); ok {
+		switch {
+		case version == 0 && len(program) == 20:
+			return ScriptWitnessV0KeyHash, [][]byte{program}
+		case version == 0 && len(program) == 32:
+			return ScriptWitnessV0ScriptHash, [][]byte{program}
+		case version == 1 && len(program) == 32:
+			return ScriptWitnessV1Taproot, [][]byte{program}
+		case bytes.Equal(script, anchorScript):
+			return ScriptAnchor, nil
+		case version != 0:
+			return ScriptWitnessUnknown, [][]byte{{version}, program}
+		}
+		return ScriptNonStandard, nil
+	}
+
+	if script.IsOpReturn() && Script(script[1:]).isPushOnly() {
+		return ScriptNullData, nil
+	}
+
+	if script.IsP2PK() && isValidPubKeySize(script[1:len(script)-1]) {
+		return ScriptPubKey, [][]byte{script[1 : len(script)-1]}
+	}
+
+	if script.IsP2PKH() {
+		return ScriptPubKeyHash, [][]byte{script[3:23]}
+	}
+
+	if m, pubkeys, ok := script.matchMultisig(); ok {
+		solutions := [][]byte{{byte(m)}}
+		solutions = append(solutions, pubkeys...)
+		return ScriptMultisig, append(solutions, []byte{byte(len(pubkeys))})
+	}
+
+	return ScriptNonStandard, nil
+}
+
+// Returns the witness version and program of any witness program, as
+// IsWitnessProgram in bitcoin's script.cpp: a small integer version
+// followed by a single direct push of 2 to 40 bytes
+func (script Script) witnessProgram() (byte, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 {
+		return 0, nil, false
+	}
+	if script[0] != OP_0 && (script[0] < OP_1 || script[0] > OP_16) {
+		return 0, nil, false
+	}
+	if int(script[1])+2 != len(script) {
+		return 0, nil, false
+	}
+
+	version, _ := smallIntValue(script[0])
+	return byte(version), script[2:], true
+}
+
+// Returns true if the script only pushes data or small integers, as
+// IsPushOnly in bitcoin's script.cpp
+func (script Script) isPushOnly() bool {
+	tokenizer := NewScriptTokenizer(script)
+	for tokenizer.Next() {
+		if tokenizer.Op().Opcode > OP_16 {
+			return false
+		}
+	}
+	return tokenizer.Err() == nil
+}
+
+// Matches "m <pubkey>... n OP_CHECKMULTISIG", as MatchMultisig in
+// bitcoin's script/solver.cpp
+func (script Script) matchMultisig() (int, [][]byte, bool) {
+	if len(script) < 1 || script[len(script)-1] != OP_CHECKMULTISIG {
+		return 0, nil, false
+	}
+
+	ops, err := Script(script[:len(script)-1]).Ops()
+	if err != nil || len(ops) < 2 {
+		return 0, nil, false
+	}
+
+	m, ok := scriptOpNumber(ops[0], 1, maxPubKeysPerMultisig)
+	if !ok {
+		return 0, nil, false
+	}
+
+	pubkeys := [][]byte{}
+	for _, op := range ops[1 : len(ops)-1] {
+		if !isValidPubKeySize(op.Data) {
+			return 0, nil, false
+		}
+		pubkeys = append(pubkeys, op.Data)
+	}
+
+	n, ok := scriptOpNumber(ops[len(ops)-1], m, maxPubKeysPerMultisig)
+	if !ok || n != len(pubkeys) {
+		return 0, nil, false
+	}
+
+	return m, pubkeys, true
+}
+
+// Returns the number an operation pushes if it is between min and max, as
+// GetScriptNumber in bitcoin's script/solver.cpp. Numbers must be small
+// integer opcodes or minimally encoded pushes
+func scriptOpNumber(op ScriptOp, min int, max int) (int, bool) {
+	num, err := op.ScriptNum(DefaultScriptNumLength, true)
+	if err != nil {
+		return 0, false
+	}
+
+	n := int(num)
+	if n < min || n > max {
+		return 0, false
+	}
+	return n, true
+}
+
+// Returns the value of OP_0 or OP_1 to OP_16
+func smallIntValue(opcode byte) (int, bool) {
+	if opcode == OP_0 {
+		return 0, true
+	}
+	if opcode >= OP_1 && opcode <= OP_16 {
+		return int(opcode-OP_1) + 1, true
+	}
+	return 0, false
+}
+
+// Returns true if the length of pubkey matches the length its prefix byte
+// implies, as CPubKey::ValidSize in bitcoin's pubkey.h
+func isValidPubKeySize(pubkey []byte) bool {
+	if len(pubkey) == 0 {
+		return false
+	}
+
+	switch pubkey[0] {
+	case 0x02, 0x03:
+		return len(pubkey) == 33
+	case 0x04, 0x06, 0x07:
+		return len(pubkey) == 65
+	}
+	return false
+}
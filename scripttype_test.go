@@ -0,0 +1,61 @@
+package blockutils
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+type testpairscripttype struct {
+	script    string
+	name      string
+	solutions string
+}
+
+func TestScriptType(t *testing.T) {
+	var tests = []testpairscripttype{
+		{"21031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126faac", "pubkey", "031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa"},
+		{"76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac", "pubkeyhash", "bdb2b538e6b07e93d6bafcef4bec9dc936818a19"},
+		{"a9144aef67ed61d391d6f3d9903ead92386c1efc992587", "scripthash", "4aef67ed61d391d6f3d9903ead92386c1efc9925"},
+		{"5121031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa2102f24f8135e2f62f81d6c4ff172fd2681a3e03cf7485510a2871ca2c41b5aa973352ae", "multisig", "01 031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa 02f24f8135e2f62f81d6c4ff172fd2681a3e03cf7485510a2871ca2c41b5aa9733 02"},
+		{"6a", "nulldata", ""},
+		{"6a24aa21a9ed735a4c6d92c7bc860c0558bf0b49feb40e553dffe846613bd6d6bac983473d2c", "nulldata", ""},
+		{"6a0051010a4c00", "nulldata", ""},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", "witness_v0_keyhash", "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"0020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d", "witness_v0_scripthash", "701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d"},
+		{"5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c", "witness_v1_taproot", "a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c"},
+		{"51024e73", "anchor", ""},
+		{"5210751e76e8199196d454941c45d1b3a323", "witness_unknown", "02 751e76e8199196d454941c45d1b3a323"},
+		{"5114751e76e8199196d454941c45d1b3a323f1433bd6", "witness_unknown", "01 751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"0010751e76e8199196d454941c45d1b3a323", "nonstandard", ""},
+		{"", "nonstandard", ""},
+		{"6a76", "nonstandard", ""},
+		{"6a4c", "nonstandard", ""},
+		{"76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ad", "nonstandard", ""},
+		{"21051ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126faac", "nonstandard", ""},
+		// Multisig with the wrong key count, a non-minimal m and an invalid key
+		{"5121031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa52ae", "nonstandard", ""},
+		{"010121031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa51ae", "nonstandard", ""},
+		{"5121051ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa51ae", "nonstandard", ""},
+		{"5221031ebf7a7e449171a1876d045279227466b82c0a855edd686f6a44adcd74b126fa51ae", "nonstandard", ""},
+	}
+
+	for _, pair := range tests {
+		script, _ := hex.DecodeString(pair.script)
+		scriptType, solutions := Script(script).Solve()
+		if scriptType.String() != pair.name {
+			t.Errorf("Incorrect type for %s. Expected %s, got %s", pair.script, pair.name, scriptType)
+		}
+		if Script(script).Type() != scriptType {
+			t.Errorf("Type and Solve disagree for %s", pair.script)
+		}
+
+		hexSolutions := make([]string, len(solutions))
+		for i := range solutions {
+			hexSolutions[i] = hex.EncodeToString(solutions[i])
+		}
+		if strings.Join(hexSolutions, " ") != pair.solutions {
+			t.Errorf("Incorrect solutions for %s. Expected %s, got %s", pair.script, pair.solutions, strings.Join(hexSolutions, " "))
+		}
+	}
+}
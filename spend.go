@@ -0,0 +1,176 @@
+package blockutils
+
+// The kinds of output an input can be recognised as spending
+type SpendType int
+
+const (
+	SpendUnknown SpendType = iota
+	SpendP2PKH
+	SpendP2SHMultisig
+	SpendP2SHP2WPKH
+	SpendP2SHP2WSH
+	SpendP2WPKH
+	SpendP2WSH
+	SpendP2TRKeyPath
+	SpendP2TRScriptPath
+)
+
+var spendTypeNames = map[SpendType]string{
+	SpendUnknown:        "unknown",
+	SpendP2PKH:          "p2pkh",
+	SpendP2SHMultisig:   "p2sh-multisig",
+	SpendP2SHP2WPKH:     "p2sh-p2wpkh",
+	SpendP2SHP2WSH:      "p2sh-p2wsh",
+	SpendP2WPKH:         "p2wpkh",
+	SpendP2WSH:          "p2wsh",
+	SpendP2TRKeyPath:    "p2tr-keypath",
+	SpendP2TRScriptPath: "p2tr-scriptpath",
+}
+
+func (t SpendType) String() string {
+	name, ok := spendTypeNames[t]
+	if !ok {
+		return "unknown"
+	}
+	return name
+}
+
+// How an input spends its previous output, along with what it reveals
+// while doing so. PubKey is set for p2pkh and p2wpkh spends, RedeemScript
+// for p2sh spends, WitnessScript for p2wsh spends, and Taproot for p2tr
+// spends
+type InputSpend struct {
+	Type          SpendType
+	PubKey        []byte
+	RedeemScript  Script
+	WitnessScript Script
+	Taproot       *TaprootSpend
+}
+
+// Returns the type of output the input spends, see Spend
+func (txin *TxInput) SpendType() SpendType {
+	return txin.Spend().Type
+}
+
+// Works out the type of output the input spends from its scriptSig and
+// witness, extracting the keys and scripts they reveal.
+//
+// If PrevOutput is attached, its script decides the type. Otherwise the
+// type is inferred from the shape of the input, which can't always tell
+// spends apart: a p2wsh spend whose witness looks like a taproot one is
+// reported as taproot, for instance
+func (txin *TxInput) Spend() *InputSpend {
+	pushes, ok := scriptPushes(txin.Script)
+	if !ok {
+		return &InputSpend{Type: SpendUnknown}
+	}
+
+	if txin.PrevOutput != nil {
+		return txin.spendOf(txin.PrevOutput.Script.Type(), pushes)
+	}
+
+	witness := txin.ScriptWitness
+	switch {
+	case len(pushes) == 0 && len(witness) > 0:
+		if isP2WPKHWitness(witness) {
+			return txin.spendOf(ScriptWitnessV0KeyHash, pushes)
+		}
+		if isTaprootWitness(witness) {
+			return txin.spendOf(ScriptWitnessV1Taproot, pushes)
+		}
+		return txin.spendOf(ScriptWitnessV0ScriptHash, pushes)
+	case len(pushes) == 1 && len(witness) > 0:
+		return txin.spendOf(ScriptScriptHash, pushes)
+	case len(pushes) == 2 && len(witness) == 0 && isValidPubKeySize(pushes[1]):
+		return txin.spendOf(ScriptPubKeyHash, pushes)
+	case len(pushes) > 0 && len(witness) == 0:
+		return txin.spendOf(ScriptScriptHash, pushes)
+	}
+	return &InputSpend{Type: SpendUnknown}
+}
+
+// Returns how the input spends an output of the given type, given the
+// data pushed by its scriptSig
+func (txin *TxInput) spendOf(outputType ScriptType, pushes [][]byte) *InputSpend {
+	witness := txin.ScriptWitness
+	spend := &InputSpend{Type: SpendUnknown}
+
+	switch outputType {
+	case ScriptPubKeyHash:
+		if len(pushes) == 2 && len(witness) == 0 {
+			spend.Type = SpendP2PKH
+			spend.PubKey = pushes[1]
+		}
+	case ScriptScriptHash:
+		if len(pushes) == 0 {
+			break
+		}
+
+		spend.RedeemScript = pushes[len(pushes)-1]
+		switch spend.RedeemScript.Type() {
+		case ScriptWitnessV0KeyHash:
+			if len(pushes) == 1 && len(witness) == 2 {
+				spend.Type = SpendP2SHP2WPKH
+				spend.PubKey = witness[1]
+			}
+		case ScriptWitnessV0ScriptHash:
+			if len(pushes) == 1 && len(witness) > 0 {
+				spend.Type = SpendP2SHP2WSH
+				spend.WitnessScript = witness[len(witness)-1]
+			}
+		case ScriptMultisig:
+			if len(witness) == 0 {
+				spend.Type = SpendP2SHMultisig
+			}
+		}
+	case ScriptWitnessV0KeyHash:
+		if len(pushes) == 0 && len(witness) == 2 {
+			spend.Type = SpendP2WPKH
+			spend.PubKey = witness[1]
+		}
+	case ScriptWitnessV0ScriptHash:
+		if len(pushes) == 0 && len(witness) > 0 {
+			spend.Type = SpendP2WSH
+			spend.WitnessScript = witness[len(witness)-1]
+		}
+	case ScriptWitnessV1Taproot:
+		taproot, err := ParseTaprootWitness(witness)
+		if len(pushes) == 0 && err == nil {
+			spend.Type = SpendP2TRScriptPath
+			if taproot.KeyPath {
+				spend.Type = SpendP2TRKeyPath
+			}
+			spend.Taproot = taproot
+		}
+	}
+	return spend
+}
+
+// Returns the data pushed by a push only script
+func scriptPushes(script Script) ([][]byte, bool) {
+	pushes := [][]byte{}
+	tokenizer := NewScriptTokenizer(script)
+	for tokenizer.Next() {
+		op := tokenizer.Op()
+		if !op.IsPush() {
+			return nil, false
+		}
+		pushes = append(pushes, op.Data)
+	}
+	return pushes, tokenizer.Err() == nil
+}
+
+// A p2wpkh witness is a signature followed by a compressed public key
+func isP2WPKHWitness(witness WitnessScript) bool {
+	return len(witness) == 2 && len(witness[1]) == 33 && isValidPubKeySize(witness[1]) && len(witness[0]) >= 9 && len(witness[0]) <= 73
+}
+
+// A taproot witness has an annex, is a lone 64 or 65 byte signature, or
+// ends with a control block for a tapscript leaf
+func isTaprootWitness(witness WitnessScript) bool {
+	taproot, err := ParseTaprootWitness(witness)
+	if err != nil {
+		return false
+	}
+	return taproot.Annex != nil || taproot.KeyPath || taproot.ControlBlock.LeafVersion == TapscriptLeafVersion
+}
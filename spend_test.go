@@ -0,0 +1,107 @@
+package blockutils
+
+import (
+	"testing"
+)
+
+type testpairspend struct {
+	txhex         string
+	spendType     SpendType
+	pubkey        string
+	redeemScript  string
+	witnessScript string
+}
+
+func TestTxInputSpend(t *testing.T) {
+	var tests = []testpairspend{
+		{digibytetx, SpendP2PKH, "02f24f8135e2f62f81d6c4ff172fd2681a3e03cf7485510a2871ca2c41b5aa9733", "", ""},
+		{"010000000197e521dff6f21a03368b2da4434104c7890931a11ec0bbd4a1630fb2baeecf9b00000000da00483045022100c24edd955100c3499b2878869226271eeb649c15e0a75b081a038e2c26fc472402201b023ca621c3ae918f1325516402066e7b98aaf036db80d05fcb4d5ead2f713c01473044022068cd12b0f5d38ed1c7da7a936ba89f27d6e4074a8c72f0fecf0b04ee55e03418022007d1d465af4fcc3c80592658e65da82565223ff302cae53ba62e836d250e3e390147522103c9078b8d06d83347b2e7e8cbbdfc24bd50e09ca1a4e5d90d70485a8c4094e5672102d52317afd128305d6fca7bd30b839e821564990c88581ebb432b478cfa95602f52aeffffffff03d07e01000000000017a9144aef67ed61d391d6f3d9903ead92386c1efc9925870000000000000000166a146f6d6e69000000000000000300000000000000c8e8030000000000001976a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac00000000", SpendP2SHMultisig, "", "522103c9078b8d06d83347b2e7e8cbbdfc24bd50e09ca1a4e5d90d70485a8c4094e5672102d52317afd128305d6fca7bd30b839e821564990c88581ebb432b478cfa95602f52ae", ""},
+		{"02000000000101b539b9e41717be24d14c06cd72aed10a1d9593a860067850116e458d96b56d660000000017160014336d166ab51b21b3ef2f0c885b7004bd3ad38b3dfeffffff0200c2eb0b000000001976a914f6a3510afba93284b4a1969bcf411a225423acd188ac4924fe020000000017a9148a4275e9d10794c5d54d0b2ef9d33cb028258c5a870247304402202a91f2110e7a06b926bb8166fbffac12552326c6099ff1f077f2f8e9a5ac74be02202d19aad053f65d30d89b99205696c8c18bebaca1a188c4f0886a0542b01d3dcc01210271f262fee7b7aba93564d0ed468018f3ccca489ef9c87032a8c9db2dc820f7a0ba671400", SpendP2SHP2WPKH, "0271f262fee7b7aba93564d0ed468018f3ccca489ef9c87032a8c9db2dc820f7a0", "0014336d166ab51b21b3ef2f0c885b7004bd3ad38b3d", ""},
+		{btcp2wshtx, SpendP2WSH, "", "", "52210375e00eb72e29da82b89367947f29ef34afb75e8654f6ea368e0acdfd92976b7c2103a1b26313f430c4b15bb1fdce663207659d8cac749a0e53d70eff01874496feff2103c96d495bfdd5ba4145e3e046fee45e84a8a48ad05bd8dbb395c011a32cf9f88053ae"},
+	}
+
+	for _, test := range tests {
+		tx, err := NewTransactionFromHexString(test.txhex)
+		if err != nil {
+			t.Fatalf("Could not parse tx: %s", err)
+		}
+
+		spend := tx.Vin[0].Spend()
+		if spend.Type != test.spendType {
+			t.Errorf("Incorrect spend type. Expected %s, got %s", test.spendType, spend.Type)
+		}
+		if ToHexString(spend.PubKey) != test.pubkey {
+			t.Errorf("Incorrect pubkey. Expected %s, got %s", test.pubkey, ToHexString(spend.PubKey))
+		}
+		if spend.RedeemScript.String() != test.redeemScript {
+			t.Errorf("Incorrect redeem script. Expected %s, got %s", test.redeemScript, spend.RedeemScript)
+		}
+		if spend.WitnessScript.String() != test.witnessScript {
+			t.Errorf("Incorrect witness script. Expected %s, got %s", test.witnessScript, spend.WitnessScript)
+		}
+	}
+}
+
+func TestTxInputSpendWitnessOnly(t *testing.T) {
+	signature := mustDecodeHex("304402202a91f2110e7a06b926bb8166fbffac12552326c6099ff1f077f2f8e9a5ac74be02202d19aad053f65d30d89b99205696c8c18bebaca1a188c4f0886a0542b01d3dcc01")
+	pubkey := mustDecodeHex("0271f262fee7b7aba93564d0ed468018f3ccca489ef9c87032a8c9db2dc820f7a0")
+	script := mustDecodeHex("20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac")
+	controlBlock := mustDecodeHex("c1187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27")
+
+	var tests = []struct {
+		witness   WitnessScript
+		spendType SpendType
+	}{
+		{WitnessScript{signature, pubkey}, SpendP2WPKH},
+		{WitnessScript{make([]byte, 64)}, SpendP2TRKeyPath},
+		{WitnessScript{make([]byte, 64), []byte{0x50}}, SpendP2TRKeyPath},
+		{WitnessScript{make([]byte, 64), script, controlBlock}, SpendP2TRScriptPath},
+		{WitnessScript{{}, signature, script}, SpendP2WSH},
+	}
+
+	for _, test := range tests {
+		txin := TxInput{ScriptWitness: test.witness}
+		if txin.SpendType() != test.spendType {
+			t.Errorf("Incorrect spend type. Expected %s, got %s", test.spendType, txin.SpendType())
+		}
+	}
+
+	txin := TxInput{ScriptWitness: WitnessScript{make([]byte, 64), script, controlBlock}}
+	spend := txin.Spend()
+	if spend.Taproot == nil || spend.Taproot.Script.String() != ToHexString(script) {
+		t.Errorf("Incorrect taproot spend. Expected script %s, got %+v", ToHexString(script), spend.Taproot)
+	}
+}
+
+func TestTxInputSpendPrevOutput(t *testing.T) {
+	witness := WitnessScript{make([]byte, 71), mustDecodeHex("0271f262fee7b7aba93564d0ed468018f3ccca489ef9c87032a8c9db2dc820f7a0")}
+	txin := TxInput{ScriptWitness: witness}
+	if txin.SpendType() != SpendP2WPKH {
+		t.Errorf("Incorrect spend type. Expected %s, got %s", SpendP2WPKH, txin.SpendType())
+	}
+
+	txin.PrevOutput = &TxOutput{Script: mustDecodeHex("0020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d")}
+	if txin.SpendType() != SpendP2WSH {
+		t.Errorf("Incorrect spend type. Expected %s, got %s", SpendP2WSH, txin.SpendType())
+	}
+
+	txin.PrevOutput = &TxOutput{Script: mustDecodeHex("76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac")}
+	if txin.SpendType() != SpendUnknown {
+		t.Errorf("Incorrect spend type. Expected %s, got %s", SpendUnknown, txin.SpendType())
+	}
+}
+
+func TestTxInputDerivedP2WSHScript(t *testing.T) {
+	tx, _ := NewTransactionFromHexString(btcp2wshtx)
+	if len(tx.Vin[0].Script) != 0 {
+		t.Errorf("Incorrect scriptSig. Expected an empty script, got %s", tx.Vin[0].Script)
+	}
+	if tx.Vin[0].DerivedP2WSHScript.String() != tx.Vout[1].Script.String() {
+		t.Errorf("Incorrect derived script. Expected %s, got %s", tx.Vout[1].Script, tx.Vin[0].DerivedP2WSHScript)
+	}
+
+	tx, _ = NewTransactionFromHexString(digibytetx)
+	if tx.Vin[0].DerivedP2WSHScript != nil {
+		t.Errorf("Incorrect derived script. Expected nil, got %s", tx.Vin[0].DerivedP2WSHScript)
+	}
+}
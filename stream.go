@@ -0,0 +1,394 @@
+package blockutils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// The largest size or count a StreamReader accepts from the data, matching
+// MAX_SIZE in bitcoin's serialize.h. Unlike a ByteReader, a stream cannot
+// check a length against the data left to read
+const maxStreamSize = 0x02000000
+
+// Reads larger than this are buffered as data arrives rather than being
+// allocated up front
+const maxStreamPrealloc = 1 << 16
+
+// Returned (wrapped in a ReadError) when the data contains a size, count
+// or number too large to be valid
+var ErrSizeTooLarge = errors.New("Size too large")
+
+// A StreamReader decodes the same little endian values as a ByteReader, but
+// from an io.Reader, so blocks and transactions can be parsed as they are
+// read from a file or HTTP body without holding the full serialization in
+// memory.
+//
+// Cursor counts the bytes consumed so far. Like ByteReader, the first
+// failed read records a sticky error, retrievable via Err.
+//
+// Unless its input is already an io.ByteReader, such as a bytes.Reader or
+// bufio.Reader, the StreamReader buffers it, and may read past the end of
+// the data it has parsed. Keep using the same StreamReader to read values
+// that follow one another in a stream.
+type StreamReader struct {
+	Cursor uint64
+	r      peekReader
+	err    error
+
+	// While a transaction is being read, hash receives every byte consumed,
+	// and txid receives those which are part of the original (non-segwit)
+	// serialization, allowing both to be computed without keeping the tx
+	// bytes around
+	hash      hash.Hash
+	txid      hash.Hash
+	stripping bool
+}
+
+// The input of a StreamReader, which needs to look ahead to detect segwit
+// txs. Satisfied by bufio.Reader
+type peekReader interface {
+	io.Reader
+	Peek(n int) ([]byte, error)
+}
+
+// Reads an io.ByteReader one byte at a time, holding on to peeked bytes
+// until they are read, so that nothing past the data parsed is consumed
+type bytePeekReader struct {
+	r      io.ByteReader
+	peeked []byte
+}
+
+func (b *bytePeekReader) Read(p []byte) (int, error) {
+	n := copy(p, b.peeked)
+	b.peeked = b.peeked[n:]
+	for n < len(p) {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		p[n] = c
+		n++
+	}
+	return n, nil
+}
+
+func (b *bytePeekReader) Peek(n int) ([]byte, error) {
+	for len(b.peeked) < n {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return b.peeked, err
+		}
+		b.peeked = append(b.peeked, c)
+	}
+	return b.peeked[:n], nil
+}
+
+// Returns a StreamReader reading from r. Readers which are not an
+// io.ByteReader are wrapped in a bufio.Reader
+func NewStreamReader(r io.Reader) *StreamReader {
+	switch reader := r.(type) {
+	case *bufio.Reader:
+		return &StreamReader{r: reader}
+	case io.ByteReader:
+		return &StreamReader{r: &bytePeekReader{r: reader}}
+	default:
+		return &StreamReader{r: bufio.NewReader(r)}
+	}
+}
+
+// Returns the first error encountered by the reader, if any
+func (s *StreamReader) Err() error {
+	return s.err
+}
+
+func (s *StreamReader) fail(field string, err error) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrUnexpectedEOF
+	}
+	s.err = &ReadError{
+		Offset: s.Cursor,
+		Field:  field,
+		Err:    err,
+	}
+}
+
+// Records bytes which have been read from the stream
+func (s *StreamReader) consume(data []byte) {
+	s.Cursor += uint64(len(data))
+	if s.hash != nil {
+		s.hash.Write(data)
+	}
+	if s.txid != nil && !s.stripping {
+		s.txid.Write(data)
+	}
+}
+
+func (s *StreamReader) read(length uint64, field string) []byte {
+	if s.err != nil {
+		return nil
+	}
+
+	if length > maxStreamSize {
+		s.fail(field, ErrSizeTooLarge)
+		return nil
+	}
+
+	var data []byte
+	if length <= maxStreamPrealloc {
+		data = make([]byte, length)
+		if _, err := io.ReadFull(s.r, data); err != nil {
+			s.fail(field, err)
+			return nil
+		}
+	} else {
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, s.r, int64(length)); err != nil {
+			s.fail(field, err)
+			return nil
+		}
+		data = buf.Bytes()
+	}
+
+	s.consume(data)
+	return data
+}
+
+func (s *StreamReader) readByte() byte {
+	data := s.read(1, "byte")
+	if data == nil {
+		return 0
+	}
+	return data[0]
+}
+
+func (s *StreamReader) ReadUint16() uint16 {
+	data := s.read(length_UINT16, "uint16")
+	if data == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(data)
+}
+
+func (s *StreamReader) ReadUint32() uint32 {
+	data := s.read(length_UINT32, "uint32")
+	if data == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(data)
+}
+
+func (s *StreamReader) ReadUint64() uint64 {
+	data := s.read(length_UINT64, "uint64")
+	if data == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(data)
+}
+
+func (s *StreamReader) ReadBytes(length uint64) []byte {
+	return s.read(length, fmt.Sprintf("%d bytes", length))
+}
+
+// Allows you to view upcoming data without consuming it. Only small
+// lookaheads, such as checking if a tx is a segwit tx, are supported
+func (s *StreamReader) PeekBytes(length uint64) []byte {
+	if s.err != nil {
+		return nil
+	}
+
+	data, err := s.r.Peek(int(length))
+	if err != nil {
+		s.fail(fmt.Sprintf("%d bytes", length), err)
+		return nil
+	}
+	return data
+}
+
+// Reads a compact size uint, as described on ByteReader.ReadCompactSizeUint
+func (s *StreamReader) ReadCompactSizeUint() uint64 {
+	intType := s.readByte()
+	switch intType {
+	case 0xFF:
+		return s.ReadUint64()
+	case 0xFE:
+		return uint64(s.ReadUint32())
+	case 0xFD:
+		return uint64(s.ReadUint16())
+	default:
+		return uint64(intType)
+	}
+}
+
+func (s *StreamReader) readCount(field string) uint64 {
+	start := s.Cursor
+	count := s.ReadCompactSizeUint()
+	if s.err == nil && count > maxStreamSize {
+		s.err = &ReadError{
+			Offset: start,
+			Field:  field,
+			Err:    ErrSizeTooLarge,
+		}
+		return 0
+	}
+	return count
+}
+
+// Parses a transaction from a StreamReader, computing its Hash and TxId
+// as it is read
+func ReadTransactionFromStream(s *StreamReader) (*Transaction, error) {
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+
+	txstartpos := s.Cursor
+	s.hash = sha256.New()
+	s.txid = sha256.New()
+	defer func() {
+		s.hash = nil
+		s.txid = nil
+		s.stripping = false
+	}()
+
+	isSegwit := false
+	version := s.ReadUint32()
+	if s.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx version: %w", s.Err())
+	}
+
+	// See ReadTransactionFromReader for details on detecting segwit txs.
+	// The marker and flag are not part of the txid serialization
+	potentialSegwitFlag := s.PeekBytes(2)
+	if s.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx inputs: %w", s.Err())
+	}
+	if isMWEBFlag(potentialSegwitFlag) {
+		return nil, fmt.Errorf("MWEB tx flag %#x: %w", potentialSegwitFlag[1], ErrUnsupportedBlockFormat)
+	}
+	if potentialSegwitFlag[0] == 0x00 && potentialSegwitFlag[1] == 0x01 {
+		isSegwit = true
+		s.stripping = true
+		s.ReadBytes(2)
+		s.stripping = false
+	}
+
+	vinsize := s.readCount("input count")
+	if s.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx inputs: %w", s.Err())
+	}
+
+	txins := make([]TxInput, 0, preallocSize(vinsize))
+	i := uint64(0)
+	for i < vinsize {
+		txin, err := readTxInput(s)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read tx input %d: %w", i, err)
+		}
+		txins = append(txins, txin)
+		i += 1
+	}
+
+	voutsize := s.readCount("output count")
+	if s.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx outputs: %w", s.Err())
+	}
+
+	txouts := make([]TxOutput, 0, preallocSize(voutsize))
+	i = uint64(0)
+	for i < voutsize {
+		txout, err := readTxOutput(s)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read tx output %d: %w", i, err)
+		}
+		txouts = append(txouts, txout)
+		i += 1
+	}
+
+	outputendpos := s.Cursor
+	if isSegwit {
+		s.stripping = true
+		witnessData, err := readWitnessData(s, vinsize)
+		s.stripping = false
+		if err != nil {
+			return nil, fmt.Errorf("Could not read tx witness data: %w", err)
+		}
+
+		attachWitnessData(txins, witnessData)
+	}
+
+	nlocktimepos := s.Cursor
+	locktime := s.ReadUint32()
+	if s.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx locktime: %w", s.Err())
+	}
+
+	txlength := s.Cursor - txstartpos
+	strippedlength := txlength
+	if isSegwit {
+		strippedlength = txlength - 2 - (nlocktimepos - outputendpos) // Without the marker, flag and witness data
+	}
+
+	tx := &Transaction{
+		Version:      version,
+		Hash:         finishDoubleSha256(s.hash),
+		TxId:         finishDoubleSha256(s.txid),
+		Vin:          txins,
+		Vout:         txouts,
+		Locktime:     locktime,
+		Size:         txlength,
+		StrippedSize: strippedlength,
+		Weight:       calcWeight(strippedlength, txlength),
+	}
+
+	return tx, nil
+}
+
+// Parses a block from a StreamReader, one transaction at a time
+func ReadBlockFromStream(s *StreamReader) (*Block, error) {
+	return readBlock(s, func() (*Transaction, error) {
+		return ReadTransactionFromStream(s)
+	})
+}
+
+// Parses a single transaction from r, such as a file or the body of a
+// getrawtransaction response.
+//
+// Unless r is an io.ByteReader, it is buffered and bytes after the
+// transaction may be consumed. To read consecutive records from r, use a
+// single NewStreamReader with ReadTransactionFromStream and
+// ReadBlockFromStream instead
+func DecodeTransaction(r io.Reader) (*Transaction, error) {
+	return ReadTransactionFromStream(NewStreamReader(r))
+}
+
+// Parses a single block from r, such as a file section or the body of a
+// getblock response, without reading the whole block into memory first.
+// Hex encoded data can be wrapped with hex.NewDecoder.
+//
+// As with DecodeTransaction, bytes after the block may be consumed unless
+// r is an io.ByteReader
+func DecodeBlock(r io.Reader) (*Block, error) {
+	return ReadBlockFromStream(NewStreamReader(r))
+}
+
+// Parses a single block from r like DecodeBlock, with its height set if
+// BIP34 was active for it on the given chain
+func DecodeBlockForChain(r io.Reader, params *ChainParams) (*Block, error) {
+	block, err := DecodeBlock(r)
+	if err != nil {
+		return nil, err
+	}
+
+	block.setChainHeight(params)
+	return block, nil
+}
+
+// Completes a sha256(sha256(data)) for data already written to hash
+func finishDoubleSha256(hash hash.Hash) Hash256 {
+	return Sha256(hash.Sum(nil))
+}
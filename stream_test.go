@@ -0,0 +1,134 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBlock(t *testing.T) {
+	block, err := DecodeBlock(hex.NewDecoder(strings.NewReader(dgb6257234)))
+	if err != nil {
+		t.Fatalf("Could not decode block stream; %s", err)
+	}
+
+	if block.Hash.String() != "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11" {
+		t.Errorf("Incorrect block hash. Expected %s, got %s", "7443ce7b891fbfb09a180320709d99e794974a1df2a87972cd3dd2c08e788c11", block.Hash)
+	}
+
+	if block.Height != 6257234 {
+		t.Errorf("Incorrect block height. Expected %d, got %d", 6257234, block.Height)
+	}
+
+	expected, _ := NewBlockFromHexString(dgb6257234)
+	for i, transaction := range block.Transactions {
+		if transaction.TxId.String() != dgb6257234TxHashes[i] {
+			t.Errorf("Incorrect txid for tx %d. Expected %s, got %s", i, dgb6257234TxHashes[i], transaction.TxId)
+		}
+
+		if transaction.Hash.String() != expected.Transactions[i].Hash.String() {
+			t.Errorf("Incorrect hash for tx %d. Expected %s, got %s", i, expected.Transactions[i].Hash, transaction.Hash)
+		}
+
+		if transaction.Size != expected.Transactions[i].Size {
+			t.Errorf("Incorrect size for tx %d. Expected %d, got %d", i, expected.Transactions[i].Size, transaction.Size)
+		}
+	}
+}
+
+func TestDecodeBlockForChain(t *testing.T) {
+	block, err := DecodeBlockForChain(hex.NewDecoder(strings.NewReader(dgb6257234)), &DigiByteParams)
+	if err != nil {
+		t.Fatalf("Could not decode block stream; %s", err)
+	}
+	if !block.HasHeight || block.Height != 6257234 {
+		t.Errorf("Incorrect block height. Expected %d, got %d", 6257234, block.Height)
+	}
+
+	// The coinbase height isn't trusted on a chain without BIP34
+	params := DigiByteParams
+	params.BIP34Height = -1
+	block, err = DecodeBlockForChain(hex.NewDecoder(strings.NewReader(dgb6257234)), &params)
+	if err != nil {
+		t.Fatalf("Could not decode block stream; %s", err)
+	}
+	if block.HasHeight || block.Height != 0 {
+		t.Errorf("Unexpected height %d for a chain without BIP34", block.Height)
+	}
+}
+
+func TestReadTransactionFromStream(t *testing.T) {
+	txbytes, _ := hex.DecodeString(digibytetxcoinbase + digibytetx)
+	stream := NewStreamReader(bytes.NewReader(txbytes))
+
+	coinbase, err := ReadTransactionFromStream(stream)
+	if err != nil {
+		t.Fatalf("Could not read first tx; %s", err)
+	}
+
+	if coinbase.TxId.String() != dgb6257234TxHashes[0] {
+		t.Errorf("Incorrect txid for segwit tx. Expected %s, got %s", dgb6257234TxHashes[0], coinbase.TxId)
+	}
+
+	if len(coinbase.Vin[0].ScriptWitness) != 1 {
+		t.Errorf("Incorrect witness stack size. Expected %d, got %d", 1, len(coinbase.Vin[0].ScriptWitness))
+	}
+
+	tx, err := ReadTransactionFromStream(stream)
+	if err != nil {
+		t.Fatalf("Could not read second tx; %s", err)
+	}
+
+	if tx.TxId.String() != dgb6257234TxHashes[1] {
+		t.Errorf("Incorrect txid for second tx. Expected %s, got %s", dgb6257234TxHashes[1], tx.TxId)
+	}
+
+	if stream.Cursor != uint64(len(txbytes)) {
+		t.Errorf("Incorrect stream position. Expected %d, got %d", len(txbytes), stream.Cursor)
+	}
+
+	_, err = ReadTransactionFromStream(stream)
+	if !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF reading past the end of the stream, got %v", err)
+	}
+}
+
+func TestDecodeConsecutiveTransactions(t *testing.T) {
+	txbytes, _ := hex.DecodeString(digibytetxcoinbase + digibytetx)
+	reader := bytes.NewReader(txbytes)
+
+	// A bytes.Reader is read directly, leaving the following tx unconsumed
+	for i := 0; i < 2; i++ {
+		tx, err := DecodeTransaction(reader)
+		if err != nil {
+			t.Fatalf("Could not decode tx %d; %s", i, err)
+		}
+		if tx.TxId.String() != dgb6257234TxHashes[i] {
+			t.Errorf("Incorrect txid for tx %d. Expected %s, got %s", i, dgb6257234TxHashes[i], tx.TxId)
+		}
+	}
+
+	if reader.Len() != 0 {
+		t.Errorf("Incorrect bytes left unread. Expected %d, got %d", 0, reader.Len())
+	}
+}
+
+func TestDecodeTruncatedBlock(t *testing.T) {
+	blockbytes, _ := hex.DecodeString(dgb6257234)
+
+	_, err := DecodeBlock(bytes.NewReader(blockbytes[:len(blockbytes)-2]))
+	if !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF for truncated block, got %v", err)
+	}
+}
+
+func TestDecodeHugeCount(t *testing.T) {
+	txbytes := []byte{0x01, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+
+	_, err := DecodeTransaction(bytes.NewReader(txbytes))
+	if !errors.Is(err, ErrSizeTooLarge) {
+		t.Errorf("Expected ErrSizeTooLarge for huge input count, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package blockutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Taproot is described in BIP341, and the scripts it commits to in BIP342
+
+// The leaf version of BIP342 tapscripts
+const TapscriptLeafVersion = 0xc0
+
+// The first byte of an annex, the optional last witness item of a taproot
+// spend
+const taprootAnnexTag = 0x50
+
+// A control block is a byte holding the leaf version and output key
+// parity, the 32 byte internal key, and up to 128 32 byte merkle path nodes
+const (
+	controlBlockBaseSize   = 33
+	controlBlockNodeSize   = 32
+	controlBlockMaxNodes   = 128
+	taprootLeafVersionMask = 0xfe
+)
+
+// Returned when a witness is not a valid taproot spend
+var ErrInvalidTaprootWitness = errors.New("Invalid taproot witness")
+
+// Returned when a control block has an invalid length
+var ErrInvalidControlBlock = errors.New("Invalid control block")
+
+// The contents of a taproot control block, which proves a script is
+// committed to by a taproot output key
+type ControlBlock struct {
+	LeafVersion byte
+	Parity      byte
+	InternalKey []byte
+	MerklePath  [][]byte
+}
+
+// A taproot input's witness, split into its parts. A key path spend only
+// has a Signature. A script path spend has the Script being executed, its
+// ControlBlock, and the Stack of inputs to the script. Either may end with
+// an Annex
+type TaprootSpend struct {
+	KeyPath      bool
+	Signature    []byte
+	Script       Script
+	ControlBlock *ControlBlock
+	Stack        [][]byte
+	Annex        []byte
+}
+
+// Returns true if the script is a pay to taproot output, "OP_1 <32 bytes>"
+func (script Script) IsP2TR() bool {
+	return script.Type() == ScriptWitnessV1Taproot
+}
+
+// Returns the 32 byte x-only output key of a pay to taproot output
+func (script Script) TaprootOutputKey() ([]byte, error) {
+	if !script.IsP2TR() {
+		return nil, errors.New("Invalid script for P2TR")
+	}
+	return script[2:34], nil
+}
+
+// Splits the witness of an input spending a taproot output into its
+// parts, as VerifyWitnessProgram in bitcoin's interpreter.cpp
+func ParseTaprootWitness(witness WitnessScript) (*TaprootSpend, error) {
+	if len(witness) == 0 {
+		return nil, fmt.Errorf("Empty witness: %w", ErrInvalidTaprootWitness)
+	}
+
+	spend := &TaprootSpend{}
+	stack := [][]byte(witness)
+	if len(stack) >= 2 && len(stack[len(stack)-1]) > 0 && stack[len(stack)-1][0] == taprootAnnexTag {
+		spend.Annex = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+	}
+
+	if len(stack) == 1 {
+		if len(stack[0]) != 64 && len(stack[0]) != 65 {
+			return nil, fmt.Errorf("Invalid signature length %d: %w", len(stack[0]), ErrInvalidTaprootWitness)
+		}
+		spend.KeyPath = true
+		spend.Signature = stack[0]
+		return spend, nil
+	}
+
+	controlBlock, err := ParseControlBlock(stack[len(stack)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	spend.ControlBlock = controlBlock
+	spend.Script = stack[len(stack)-2]
+	spend.Stack = stack[:len(stack)-2]
+	return spend, nil
+}
+
+// Parses a taproot control block
+func ParseControlBlock(data []byte) (*ControlBlock, error) {
+	if len(data) < controlBlockBaseSize || (len(data)-controlBlockBaseSize)%controlBlockNodeSize != 0 {
+		return nil, fmt.Errorf("Length %d: %w", len(data), ErrInvalidControlBlock)
+	}
+
+	nodes := (len(data) - controlBlockBaseSize) / controlBlockNodeSize
+	if nodes > controlBlockMaxNodes {
+		return nil, fmt.Errorf("%d merkle path nodes: %w", nodes, ErrInvalidControlBlock)
+	}
+
+	path := make([][]byte, nodes)
+	for i := range path {
+		offset := controlBlockBaseSize + i*controlBlockNodeSize
+		path[i] = data[offset : offset+controlBlockNodeSize]
+	}
+
+	return &ControlBlock{
+		LeafVersion: data[0] & taprootLeafVersionMask,
+		Parity:      data[0] & 0x01,
+		InternalKey: data[1:controlBlockBaseSize],
+		MerklePath:  path,
+	}, nil
+}
+
+// Returns the merkle root committed to by the taproot output key, given
+// the leaf hash of the script being spent
+func (cb *ControlBlock) MerkleRoot(leafHash []byte) []byte {
+	root := leafHash
+	for _, node := range cb.MerklePath {
+		root = TapBranchHash(root, node)
+	}
+	return root
+}
+
+// Returns the hash of a script leaf of a taproot tree
+func TapLeafHash(leafVersion byte, script Script) []byte {
+	writer := ByteWriter{}
+	writer.WriteBytes([]byte{leafVersion & taprootLeafVersionMask})
+	writer.WriteVarBytes(script)
+	return TaggedHash("TapLeaf", writer.Bytes)
+}
+
+// Returns the hash of a branch of a taproot tree. The children are hashed
+// in sorted order, so the order they are given in does not matter
+func TapBranchHash(a []byte, b []byte) []byte {
+	if bytes.Compare(b, a) < 0 {
+		a, b = b, a
+	}
+	return TaggedHash("TapBranch", append(append([]byte{}, a...), b...))
+}
+
+// Returns sha256(sha256(tag) || sha256(tag) || data), as described in
+// BIP340
+func TaggedHash(tag string, data []byte) []byte {
+	tagHash := Sha256([]byte(tag))
+	return Sha256(append(append(append([]byte{}, tagHash...), tagHash...), data...))
+}
@@ -0,0 +1,141 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func mustDecodeHex(hexstring string) []byte {
+	data, _ := hex.DecodeString(hexstring)
+	return data
+}
+
+func TestScriptP2TR(t *testing.T) {
+	// From the BIP341 wallet test vectors
+	script := Script(mustDecodeHex("5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3"))
+	if !script.IsP2TR() || !script.IsWitnessScript() {
+		t.Error("Incorrectly declared script as non-P2TR")
+	}
+
+	key, err := script.TaprootOutputKey()
+	if err != nil || hex.EncodeToString(key) != "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3" {
+		t.Errorf("Incorrect output key. Expected %s, got %x (%v)", "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3", key, err)
+	}
+
+	version, err := script.WitnessVersion()
+	if err != nil || version != 1 {
+		t.Errorf("Incorrect witness version. Expected %d, got %d (%v)", 1, version, err)
+	}
+
+	address, _ := script.Address(&AddressParamsBitcoin)
+	if address != "bc1pz37fc4cn9ah8anwm4xqqhvxygjf9rjf2resrw8h8w4tmvcs0863sa2e586" {
+		t.Errorf("Incorrect address. Expected %s, got %s", "bc1pz37fc4cn9ah8anwm4xqqhvxygjf9rjf2resrw8h8w4tmvcs0863sa2e586", address)
+	}
+
+	p2wpkh := Script(mustDecodeHex("0014751e76e8199196d454941c45d1b3a323f1433bd6"))
+	if p2wpkh.IsP2TR() {
+		t.Error("Incorrectly declared P2WPKH script as P2TR")
+	}
+	if _, err := p2wpkh.TaprootOutputKey(); err == nil {
+		t.Error("Expected an error reading the output key of a P2WPKH script")
+	}
+}
+
+func TestTapLeafHash(t *testing.T) {
+	script := Script(mustDecodeHex("20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac"))
+	leaf := TapLeafHash(TapscriptLeafVersion, script)
+	if hex.EncodeToString(leaf) != "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21" {
+		t.Errorf("Incorrect leaf hash. Expected %s, got %x", "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21", leaf)
+	}
+
+	other := TapLeafHash(TapscriptLeafVersion, Script{OP_TRUE})
+	expected := "87ee479295a15cbd19a2493c872b5b763ac808cb8c198e22f23f7847f8e9c2dd"
+	if hex.EncodeToString(TapBranchHash(leaf, other)) != expected || hex.EncodeToString(TapBranchHash(other, leaf)) != expected {
+		t.Errorf("Incorrect branch hash. Expected %s, got %x", expected, TapBranchHash(leaf, other))
+	}
+}
+
+func TestParseTaprootWitnessKeyPath(t *testing.T) {
+	signature := bytes.Repeat([]byte{0x01}, 64)
+
+	spend, err := ParseTaprootWitness(WitnessScript{signature})
+	if err != nil {
+		t.Fatalf("Could not parse witness: %s", err)
+	}
+	if !spend.KeyPath || !bytes.Equal(spend.Signature, signature) || spend.Annex != nil || spend.ControlBlock != nil {
+		t.Errorf("Incorrect key path spend: %+v", spend)
+	}
+
+	// A sighash type byte may follow the signature, and an annex the stack
+	annex := []byte{0x50, 0xaa}
+	spend, err = ParseTaprootWitness(WitnessScript{append(signature, 0x83), annex})
+	if err != nil {
+		t.Fatalf("Could not parse witness: %s", err)
+	}
+	if !spend.KeyPath || len(spend.Signature) != 65 || !bytes.Equal(spend.Annex, annex) {
+		t.Errorf("Incorrect key path spend with annex: %+v", spend)
+	}
+}
+
+func TestParseTaprootWitnessScriptPath(t *testing.T) {
+	script := Script(mustDecodeHex("20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac"))
+	sibling := TapLeafHash(TapscriptLeafVersion, Script{OP_TRUE})
+	controlBlock := append(mustDecodeHex("c1187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27"), sibling...)
+	signature := bytes.Repeat([]byte{0x02}, 64)
+	annex := []byte{0x50}
+
+	spend, err := ParseTaprootWitness(WitnessScript{signature, script, controlBlock, annex})
+	if err != nil {
+		t.Fatalf("Could not parse witness: %s", err)
+	}
+
+	if spend.KeyPath || !bytes.Equal(spend.Script, script) || !bytes.Equal(spend.Annex, annex) {
+		t.Errorf("Incorrect script path spend: %+v", spend)
+	}
+	if len(spend.Stack) != 1 || !bytes.Equal(spend.Stack[0], signature) {
+		t.Errorf("Incorrect script inputs. Expected [%x], got %x", signature, spend.Stack)
+	}
+
+	cb := spend.ControlBlock
+	if cb.LeafVersion != TapscriptLeafVersion || cb.Parity != 1 {
+		t.Errorf("Incorrect leaf version or parity. Expected %x and %d, got %x and %d", TapscriptLeafVersion, 1, cb.LeafVersion, cb.Parity)
+	}
+	if hex.EncodeToString(cb.InternalKey) != "187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27" {
+		t.Errorf("Incorrect internal key. Got %x", cb.InternalKey)
+	}
+	if len(cb.MerklePath) != 1 || !bytes.Equal(cb.MerklePath[0], sibling) {
+		t.Errorf("Incorrect merkle path. Got %x", cb.MerklePath)
+	}
+
+	root := cb.MerkleRoot(TapLeafHash(cb.LeafVersion, spend.Script))
+	if hex.EncodeToString(root) != "87ee479295a15cbd19a2493c872b5b763ac808cb8c198e22f23f7847f8e9c2dd" {
+		t.Errorf("Incorrect merkle root. Expected %s, got %x", "87ee479295a15cbd19a2493c872b5b763ac808cb8c198e22f23f7847f8e9c2dd", root)
+	}
+}
+
+func TestParseTaprootWitnessErrors(t *testing.T) {
+	var tests = []struct {
+		witness WitnessScript
+		err     error
+	}{
+		{WitnessScript{}, ErrInvalidTaprootWitness},
+		{WitnessScript{make([]byte, 63)}, ErrInvalidTaprootWitness},
+		{WitnessScript{make([]byte, 66)}, ErrInvalidTaprootWitness},
+		{WitnessScript{[]byte{0x50}}, ErrInvalidTaprootWitness},
+		{WitnessScript{{0x51}, make([]byte, 32)}, ErrInvalidControlBlock},
+		{WitnessScript{{0x51}, make([]byte, 34)}, ErrInvalidControlBlock},
+		{WitnessScript{{0x51}, make([]byte, 33+32*129)}, ErrInvalidControlBlock},
+	}
+
+	for i, test := range tests {
+		if _, err := ParseTaprootWitness(test.witness); !errors.Is(err, test.err) {
+			t.Errorf("Incorrect error for test %d. Expected %s, got %v", i, test.err, err)
+		}
+	}
+
+	if _, err := ParseControlBlock(make([]byte, 33+32*128)); err != nil {
+		t.Errorf("Could not parse control block with 128 nodes: %s", err)
+	}
+}
@@ -2,8 +2,17 @@ package blockutils
 
 import (
 	"encoding/hex"
+	"fmt"
 )
 
+// Non-witness data counts this many times more towards weight than
+// witness data
+const witnessScaleFactor = 4
+
+// Litecoin txs carrying MWEB data set this bit in the flag byte which
+// follows the segwit marker
+const mwebTxFlag = 0x08
+
 // Bitcoin witness script type backed by a 2d byte array
 // The string function is particularly helpful for working
 // with the stack and getting it into a string representation
@@ -11,16 +20,23 @@ type WitnessScript [][]byte
 
 // A Transaction represents a complete Bitcoin-like transaction
 //
-// TxId should be used for the transaction id
+// TxId should be used for the transaction id. Hash includes the witness
+// data, making it the wtxid (BIP141) for segwit transactions
+//
+// Size is the full serialized length, StrippedSize the length without
+// the segwit marker, flag and witness data, and Weight is
+// StrippedSize*3 + Size as defined in BIP141
 
 type Transaction struct {
-	Hash     Hash256 // not actually in blockchain data; calculated
-	TxId     Hash256 // not actually in blockchain data; calculated
-	Version  uint32
-	Locktime uint32
-	Vin      []TxInput
-	Vout     []TxOutput
-	Size     uint64
+	Hash         Hash256 // not actually in blockchain data; calculated
+	TxId         Hash256 // not actually in blockchain data; calculated
+	Version      uint32
+	Locktime     uint32
+	Vin          []TxInput
+	Vout         []TxOutput
+	Size         uint64
+	StrippedSize uint64 // calculated
+	Weight       uint64 // calculated
 }
 
 // Represents a single transaction output
@@ -34,12 +50,21 @@ type Transaction struct {
 // and Hash is set to a null hash
 // (0000000000000000000000000000000000000000000000000000000000000000), and
 // Script contains the coinbase script
+//
+// PrevOutput is not part of the tx data, and is nil unless the output
+// being spent has been attached, such as with Block.AttachUndo
+//
+// DerivedP2WSHScript is not part of the tx data either. It is the P2WSH
+// output script derived from the witness script of inputs that look like
+// P2WSH spends (see Spend), and is nil for every other input
 type TxInput struct {
-	Hash          Hash256
-	Index         uint32
-	Script        Script
-	Sequence      uint32
-	ScriptWitness WitnessScript
+	Hash               Hash256
+	Index              uint32
+	Script             Script
+	Sequence           uint32
+	ScriptWitness      WitnessScript
+	PrevOutput         *TxOutput
+	DerivedP2WSHScript Script
 }
 
 // Represents a single transaction output, composed of its value and script
@@ -48,7 +73,7 @@ type TxOutput struct {
 	Script Script
 }
 
-func readTxInput(txreader *ByteReader) (txin TxInput, err error) {
+func readTxInput(txreader byteSource) (txin TxInput, err error) {
 	previoushash := txreader.ReadBytes(32)         // The first 32 bytes of a tx input are the prev hash
 	vout := txreader.ReadUint32()                  // ... followed by the vout index in the previous tx
 	scriptlength := txreader.ReadCompactSizeUint() // ... followed up the scriptSig length
@@ -61,10 +86,10 @@ func readTxInput(txreader *ByteReader) (txin TxInput, err error) {
 		Script:   script,
 		Sequence: sequence,
 	}
-	return txin, err
+	return txin, txreader.Err()
 }
 
-func readTxOutput(txreader *ByteReader) (txout TxOutput, err error) {
+func readTxOutput(txreader byteSource) (txout TxOutput, err error) {
 	value := txreader.ReadUint64()                 // First 8 bytes are the value of the output
 	scriptlength := txreader.ReadCompactSizeUint() // ... followed up the script length
 	script := txreader.ReadBytes(scriptlength)     // ... followed by the actual script
@@ -74,23 +99,25 @@ func readTxOutput(txreader *ByteReader) (txout TxOutput, err error) {
 		Script: script,
 	}
 
-	return txout, err
+	return txout, txreader.Err()
 }
 
-func readWitnessData(txreader *ByteReader, vinsize uint64) (witnessData [][][]byte, err error) {
+func readWitnessData(txreader byteSource, vinsize uint64) (witnessData [][][]byte, err error) {
 	i := uint64(0)
 	witnessData = make([][][]byte, vinsize)
 	for i < vinsize { // There is one witness stack for each input
-		stackSize := txreader.ReadCompactSizeUint() // Each stack has a length defined by a compact int
-		witnessData[i] = make([][]byte, stackSize)
+		stackSize := txreader.readCount("witness stack size") // Each stack has a length defined by a compact int
+		witnessData[i] = make([][]byte, 0, preallocSize(stackSize))
 		j := uint64(0)
-		for j < stackSize {
+		for j < stackSize && txreader.Err() == nil {
 			stackItemLength := txreader.ReadCompactSizeUint() // Each stack item's length is also defined by a compact int
-			witnessData[i][j] = make([]byte, stackItemLength)
-			stackItem := txreader.ReadBytes(stackItemLength) // Read the actual stack item
-			witnessData[i][j] = stackItem
+			stackItem := txreader.ReadBytes(stackItemLength)  // Read the actual stack item
+			witnessData[i] = append(witnessData[i], stackItem)
 			j += 1
 		}
+		if txreader.Err() != nil {
+			return nil, txreader.Err()
+		}
 		i += 1
 	}
 	return witnessData, nil
@@ -120,6 +147,9 @@ func NewTransactionFromHexString(hexstring string) (*Transaction, error) {
 
 // Parses a transaction from a ByteReader. This is to be used when parsing
 // an entire block
+//
+// Truncated data results in an error wrapping a *ReadError (and
+// ErrUnexpectedEOF) describing where parsing stopped
 func ReadTransactionFromReader(b *ByteReader) (*Transaction, error) {
 	var err error
 	isSegwit := false
@@ -127,6 +157,9 @@ func ReadTransactionFromReader(b *ByteReader) (*Transaction, error) {
 	txstartpos := b.Cursor
 	// First 4 bytes of a tx are the tx version; most chains only have version 1
 	version := b.ReadUint32()
+	if b.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx version: %w", b.Err())
+	}
 
 	// If this is a segwit tx, the first two bytes following the version will be 0x00 0x01
 	// We can peek these bytes to see if it is a purely segwit tx
@@ -134,31 +167,43 @@ func ReadTransactionFromReader(b *ByteReader) (*Transaction, error) {
 	// for coinbase transactions, where the following byte will then never be 0x01, as the input
 	// tx is a null hash in coinbase transactions
 	potentialSegwitFlag := b.PeekBytes(2)
+	if b.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx inputs: %w", b.Err())
+	}
+	if isMWEBFlag(potentialSegwitFlag) {
+		return nil, fmt.Errorf("MWEB tx flag %#x: %w", potentialSegwitFlag[1], ErrUnsupportedBlockFormat)
+	}
 	if potentialSegwitFlag[0] == 0x00 && potentialSegwitFlag[1] == 0x01 {
 		isSegwit = true
 		b.ReadBytes(2)
 	}
 
 	// After the version is a variable int specifying how many inputs this tx has
-	vinsize := b.ReadCompactSizeUint()
+	vinsize := b.readCount("input count")
+	if b.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx inputs: %w", b.Err())
+	}
 
 	i := uint64(0)
 	txins := make([]TxInput, vinsize)
 	for i < vinsize {
 		txins[i], err = readTxInput(b)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("Could not read tx input %d: %w", i, err)
 		}
 		i += 1
 	}
 
-	voutsize := b.ReadCompactSizeUint()
+	voutsize := b.readCount("output count")
+	if b.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx outputs: %w", b.Err())
+	}
 	txouts := make([]TxOutput, voutsize)
 	i = uint64(0)
 	for i < voutsize {
 		txouts[i], err = readTxOutput(b)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("Could not read tx output %d: %w", i, err)
 		}
 		i += 1
 	}
@@ -167,28 +212,28 @@ func ReadTransactionFromReader(b *ByteReader) (*Transaction, error) {
 	if isSegwit {
 		witnessData, err := readWitnessData(b, vinsize)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("Could not read tx witness data: %w", err)
 		}
 
-		for i, _ := range txins {
-			txins[i].ScriptWitness = witnessData[i]
-			if len(txins[i].Script) == 0 {
-				txins[i].Script = append([]byte{0x00, 0x20}, Script(Sha256(witnessData[i][len(witnessData[i])-1]))...)
-			}
-		}
+		attachWitnessData(txins, witnessData)
 	}
 
 	nlocktimepos := b.Cursor
 	locktime := b.ReadUint32() // The Lock time is always the last 4 bytes of a tx
+	if b.Err() != nil {
+		return nil, fmt.Errorf("Could not read tx locktime: %w", b.Err())
+	}
 
 	txlength := nlocktimepos - txstartpos + 4
 
 	hash := DoubleSha256(b.PeekBytesFrom(txstartpos, txlength))
 	txid := hash // Tx ID is the same as the hash for non-segwit transactions
 
+	strippedlength := txlength
 	if isSegwit {
 		originalFormat := b.stripSegwit(txstartpos, outputendpos, nlocktimepos) // This duplicates the original transaction and does not modify the underlying array
 		txid = DoubleSha256(originalFormat)
+		strippedlength = uint64(len(originalFormat))
 	}
 
 	// if AllZero(txins[0].Hash) {
@@ -196,20 +241,141 @@ func ReadTransactionFromReader(b *ByteReader) (*Transaction, error) {
 	// }
 
 	tx := &Transaction{
-		Version:  version,
-		Hash:     hash,
-		TxId:     txid,
-		Vin:      txins,
-		Vout:     txouts,
-		Locktime: locktime,
-		Size:     txlength,
+		Version:      version,
+		Hash:         hash,
+		TxId:         txid,
+		Vin:          txins,
+		Vout:         txouts,
+		Locktime:     locktime,
+		Size:         txlength,
+		StrippedSize: strippedlength,
+		Weight:       calcWeight(strippedlength, txlength),
 	}
 
 	return tx, nil
 }
 
+// Returns true if the bytes following a tx version are the segwit marker
+// and a flag with the MWEB bit set, with or without witness data
+func isMWEBFlag(marker []byte) bool {
+	return marker[0] == 0x00 && (marker[1] == mwebTxFlag || marker[1] == mwebTxFlag|0x01)
+}
+
+// Assigns each witness stack to its input, deriving the P2WSH output
+// script of inputs that look like P2WSH spends
+func attachWitnessData(txins []TxInput, witnessData [][][]byte) {
+	for i, _ := range txins {
+		txins[i].ScriptWitness = witnessData[i]
+		spend := txins[i].Spend()
+		if spend.Type == SpendP2WSH {
+			txins[i].DerivedP2WSHScript = newWitnessScript(0, Sha256(spend.WitnessScript))
+		}
+	}
+}
+
 // Returns true if a transaction is a coinbase tx,
 // false otherwise
 func (tx *Transaction) IsCoinbase() bool {
+	if len(tx.Vin) == 0 {
+		return false
+	}
 	return AllZero(tx.Vin[0].Hash)
 }
+
+// Returns the witness transaction id, the hash of the tx including its
+// witness data. This is the same as the TxId for non-segwit txs
+func (tx *Transaction) WTxId() Hash256 {
+	return tx.Hash
+}
+
+// Returns the virtual size of the tx, its weight divided by 4 and rounded
+// up, which fee rates are expressed in
+func (tx *Transaction) VSize() uint64 {
+	return (tx.Weight + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
+// Returns the BIP141 weight of data with the given stripped and total sizes
+func calcWeight(strippedSize uint64, size uint64) uint64 {
+	return strippedSize*(witnessScaleFactor-1) + size
+}
+
+// Returns true if any input of the transaction has witness data
+func (tx *Transaction) HasWitness() bool {
+	for _, txin := range tx.Vin {
+		if len(txin.ScriptWitness) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the tx in its wire format. Witness data is only included if
+// withWitness is set and the tx has any; without it, the serialization
+// hashes to the TxId
+func (tx *Transaction) writeTo(w *ByteWriter, withWitness bool) {
+	withWitness = withWitness && tx.HasWitness()
+
+	w.WriteUint32(tx.Version)
+	if withWitness {
+		w.WriteBytes([]byte{0x00, 0x01}) // segwit marker and flag
+	}
+
+	w.WriteCompactSizeUint(uint64(len(tx.Vin)))
+	for i := range tx.Vin {
+		txin := &tx.Vin[i]
+		w.WriteBytes(txin.Hash)
+		w.WriteUint32(txin.Index)
+		w.WriteVarBytes(txin.Script)
+		w.WriteUint32(txin.Sequence)
+	}
+
+	w.WriteCompactSizeUint(uint64(len(tx.Vout)))
+	for _, txout := range tx.Vout {
+		w.WriteUint64(txout.Value)
+		w.WriteVarBytes(txout.Script)
+	}
+
+	if withWitness {
+		for _, txin := range tx.Vin {
+			w.WriteCompactSizeUint(uint64(len(txin.ScriptWitness)))
+			for _, item := range txin.ScriptWitness {
+				w.WriteVarBytes(item)
+			}
+		}
+	}
+
+	w.WriteUint32(tx.Locktime)
+}
+
+// Returns the tx in its wire format, such as for sendrawtransaction.
+// Without witness data, the result is the original serialization
+// which hashes to the TxId. Inputs are written from their Script and
+// ScriptWitness alone, so fields which are not part of the tx data, such
+// as PrevOutput and DerivedP2WSHScript, never affect the result
+func (tx *Transaction) Serialize(withWitness bool) []byte {
+	writer := ByteWriter{}
+	tx.writeTo(&writer, withWitness)
+	return writer.Bytes
+}
+
+// Returns the hex encoded wire format of the tx, including witness data
+func (tx *Transaction) Hex() string {
+	return hex.EncodeToString(tx.Serialize(true))
+}
+
+// Implements encoding.BinaryMarshaler, including witness data
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	return tx.Serialize(true), nil
+}
+
+// Implements encoding.BinaryUnmarshaler, replacing tx with the
+// transaction parsed from data
+func (tx *Transaction) UnmarshalBinary(data []byte) error {
+	parsed, err := NewTransactionFromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	*tx = *parsed
+	return nil
+}
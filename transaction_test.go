@@ -1,7 +1,10 @@
 package blockutils
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -225,9 +228,11 @@ func ExampleNewTransactionFromHexString_second() {
 		fmt.Println("\n\tTransaction Inputs:")
 		fmt.Printf("\tHash: %s\n", txin.Hash)
 		fmt.Printf("\tIndex: %d\n", txin.Index)
-		fmt.Printf("\tScript: %s\n", txin.Script)
+		fmt.Printf("\tScript: %q\n", txin.Script.String())
 		fmt.Printf("\tSequence: %d\n", txin.Sequence)
 		fmt.Printf("\tScriptWitness: %s\n", txin.ScriptWitness)
+		fmt.Printf("\tSpendType: %s\n", txin.SpendType())
+		fmt.Printf("\tDerivedP2WSHScript: %s\n", txin.DerivedP2WSHScript)
 	}
 	for _, txout := range tx.Vout {
 		fmt.Println("\n\tTransaction Outputs:")
@@ -244,9 +249,11 @@ func ExampleNewTransactionFromHexString_second() {
 	// 	Transaction Inputs:
 	// 	Hash: d40bfecca542045b24809b02bbcb74b4627ea42ed95cd9eb1361ec9c9ca05985
 	// 	Index: 7
-	// 	Script: 0020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d
+	// 	Script: ""
 	// 	Sequence: 4294967295
 	// 	ScriptWitness: [ 30440220515ad25b217558f0f8bb3b415c0ab6163e0e6fcea4c555b320a1366eb9e62b1d02203790721467854b53b79d3ce72cc74d448d13836db5b30add0d84ac1b38d5237001 304402207c3487d85fe8852316b532a2703ca0d86c642128a3f264098391c0901ccbd1f202207ec8d2aa6099e8aab742c8103bd487ac275c3416780e7478206986a6d7e5600201 52210375e00eb72e29da82b89367947f29ef34afb75e8654f6ea368e0acdfd92976b7c2103a1b26313f430c4b15bb1fdce663207659d8cac749a0e53d70eff01874496feff2103c96d495bfdd5ba4145e3e046fee45e84a8a48ad05bd8dbb395c011a32cf9f88053ae]
+	// 	SpendType: p2wsh
+	// 	DerivedP2WSHScript: 0020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d
 	//
 	// 	Transaction Outputs:
 	// 	Value: 29000000
@@ -309,3 +316,118 @@ func ExampleNewTransactionFromHexString_third() {
 	// 	Value: 1000
 	// 	Script: 76a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1988ac
 }
+
+func TestTruncatedTx(t *testing.T) {
+	txbytes, _ := hex.DecodeString(digibytetx)
+
+	for _, length := range []int{0, 3, 5, 50, 200, len(txbytes) - 1} {
+		tx, err := NewTransactionFromBytes(txbytes[:length])
+		if err == nil {
+			t.Errorf("Expected error parsing tx truncated to %d bytes, got tx %s", length, tx.TxId)
+			continue
+		}
+
+		if !errors.Is(err, ErrUnexpectedEOF) {
+			t.Errorf("Expected ErrUnexpectedEOF for tx truncated to %d bytes, got %s", length, err)
+		}
+	}
+
+	segwitbytes, _ := hex.DecodeString(digibytetxcoinbase)
+	_, err := NewTransactionFromBytes(segwitbytes[:len(segwitbytes)-10])
+	if !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF for truncated segwit tx, got %v", err)
+	}
+}
+
+// A P2WSH spend with an empty scriptSig
+var btcp2wshtx = "010000000001018559a09c9cec6113ebd95cd92ea47e62b474cbbb029b80245b0442a5ccfe0bd40700000000ffffffff024081ba010000000017a9144820500835190c3b44384a483470e43b22bcdba187df5ca40200000000220020701a8d401c84fb13e6baf169d59684e17abd9fa216c8cc5b9fc63d622ff8c58d04004730440220515ad25b217558f0f8bb3b415c0ab6163e0e6fcea4c555b320a1366eb9e62b1d02203790721467854b53b79d3ce72cc74d448d13836db5b30add0d84ac1b38d523700147304402207c3487d85fe8852316b532a2703ca0d86c642128a3f264098391c0901ccbd1f202207ec8d2aa6099e8aab742c8103bd487ac275c3416780e7478206986a6d7e56002016952210375e00eb72e29da82b89367947f29ef34afb75e8654f6ea368e0acdfd92976b7c2103a1b26313f430c4b15bb1fdce663207659d8cac749a0e53d70eff01874496feff2103c96d495bfdd5ba4145e3e046fee45e84a8a48ad05bd8dbb395c011a32cf9f88053ae00000000"
+
+func TestTxSerializeRoundTrip(t *testing.T) {
+	for _, txhex := range []string{digibytetx, digibytetxcoinbase, btcp2wshtx} {
+		tx, err := NewTransactionFromHexString(txhex)
+		if err != nil {
+			t.Fatalf("Could not parse tx hex; %s", err)
+		}
+
+		if tx.Hex() != txhex {
+			t.Errorf("Serialized tx did not match. Expected %s, got %s", txhex, tx.Hex())
+		}
+
+		if DoubleSha256(tx.Serialize(false)).String() != tx.TxId.String() {
+			t.Errorf("Serialization without witness did not hash to txid %s", tx.TxId)
+		}
+
+		data, _ := tx.MarshalBinary()
+		var decoded Transaction
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Errorf("Could not unmarshal tx; %s", err)
+		}
+
+		if decoded.TxId.String() != tx.TxId.String() {
+			t.Errorf("Unmarshaled txid did not match. Expected %s, got %s", tx.TxId, decoded.TxId)
+		}
+	}
+}
+
+// Segwit inputs keep their real scriptSig, which may be empty, so spends
+// of any kind round trip without depending on which data was derived
+func TestTxSerializeSegwitInputs(t *testing.T) {
+	p2shp2wpkhtx := "02000000000101b539b9e41717be24d14c06cd72aed10a1d9593a860067850116e458d96b56d660000000017160014336d166ab51b21b3ef2f0c885b7004bd3ad38b3dfeffffff0200c2eb0b000000001976a914f6a3510afba93284b4a1969bcf411a225423acd188ac4924fe020000000017a9148a4275e9d10794c5d54d0b2ef9d33cb028258c5a870247304402202a91f2110e7a06b926bb8166fbffac12552326c6099ff1f077f2f8e9a5ac74be02202d19aad053f65d30d89b99205696c8c18bebaca1a188c4f0886a0542b01d3dcc01210271f262fee7b7aba93564d0ed468018f3ccca489ef9c87032a8c9db2dc820f7a0ba671400"
+	for _, txhex := range []string{p2shp2wpkhtx, btcp2wshtx} {
+		tx, err := NewTransactionFromHexString(txhex)
+		if err != nil {
+			t.Fatalf("Could not parse tx hex; %s", err)
+		}
+
+		tx.Vin[0].DerivedP2WSHScript = Script{OP_RETURN}
+		tx.Vin[0].PrevOutput = &TxOutput{Script: Script{OP_TRUE}}
+		if tx.Hex() != txhex {
+			t.Errorf("Serialized tx did not match. Expected %s, got %s", txhex, tx.Hex())
+		}
+	}
+
+	// A P2WPKH spend, whose witness does not end with a script
+	tx, _ := NewTransactionFromHexString(btcp2wshtx)
+	tx.Vin[0].ScriptWitness = WitnessScript{tx.Vin[0].ScriptWitness[1], mustDecodeHex("0375e00eb72e29da82b89367947f29ef34afb75e8654f6ea368e0acdfd92976b7c")}
+	decoded, err := NewTransactionFromBytes(tx.Serialize(true))
+	if err != nil {
+		t.Fatalf("Could not parse serialized tx; %s", err)
+	}
+
+	if len(decoded.Vin[0].Script) != 0 || decoded.Vin[0].DerivedP2WSHScript != nil {
+		t.Errorf("Incorrect P2WPKH input. Expected an empty script and no derived script, got %s and %s", decoded.Vin[0].Script, decoded.Vin[0].DerivedP2WSHScript)
+	}
+	if decoded.Hex() != tx.Hex() {
+		t.Errorf("Serialized tx did not match. Expected %s, got %s", tx.Hex(), decoded.Hex())
+	}
+}
+
+func TestTxWeight(t *testing.T) {
+	tx, _ := NewTransactionFromHexString(btcp2wshtx)
+
+	if tx.Size != 380 || tx.StrippedSize != 126 || tx.Weight != 758 || tx.VSize() != 190 {
+		t.Errorf("Incorrect segwit tx sizes. Expected size %d, stripped %d, weight %d, vsize %d; got %d, %d, %d, %d", 380, 126, 758, 190, tx.Size, tx.StrippedSize, tx.Weight, tx.VSize())
+	}
+
+	if tx.StrippedSize != uint64(len(tx.Serialize(false))) {
+		t.Errorf("Stripped size did not match serialization without witness. Expected %d, got %d", len(tx.Serialize(false)), tx.StrippedSize)
+	}
+
+	legacy, _ := NewTransactionFromHexString(digibytetx)
+	if legacy.StrippedSize != 373 || legacy.Weight != 4*373 || legacy.VSize() != 373 {
+		t.Errorf("Incorrect legacy tx sizes. Expected stripped %d, weight %d, vsize %d; got %d, %d, %d", 373, 4*373, 373, legacy.StrippedSize, legacy.Weight, legacy.VSize())
+	}
+}
+
+func TestMWEBTxUnsupported(t *testing.T) {
+	for _, flag := range []string{"0008", "0009"} {
+		mwebtx := digibytetx[:8] + flag + digibytetx[8:]
+		if _, err := NewTransactionFromHexString(mwebtx); !errors.Is(err, ErrUnsupportedBlockFormat) {
+			t.Errorf("Incorrect error for MWEB flag %s. Expected %s, got %v", flag, ErrUnsupportedBlockFormat, err)
+		}
+
+		if _, err := DecodeTransaction(hex.NewDecoder(strings.NewReader(mwebtx))); !errors.Is(err, ErrUnsupportedBlockFormat) {
+			t.Errorf("Incorrect error decoding MWEB flag %s. Expected %s, got %v", flag, ErrUnsupportedBlockFormat, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package blockutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Each undo record in a rev*.dat file is followed by a checksum of the
+// previous block hash and the undo data
+const undoChecksumLength = 32
+
+// Returned when undo data does not line up with the inputs of a block
+var ErrUndoMismatch = errors.New("Undo data does not match block")
+
+// The undo data for a single transaction, holding the outputs spent by
+// each of its inputs, in order
+type TxUndo struct {
+	PrevOutputs []Coin
+}
+
+// The undo data for a block, as written by bitcoin to rev*.dat files. It
+// holds a TxUndo for each transaction in the block, except the coinbase
+type BlockUndo struct {
+	TxUndos []TxUndo
+}
+
+// An undo record read from a rev*.dat file, along with where it was found
+type FileUndo struct {
+	*BlockUndo
+	File     string
+	Offset   int64
+	Size     uint32
+	Checksum Hash256
+	data     []byte
+}
+
+// Returns block undo data parsed from the given bytes
+func NewBlockUndoFromBytes(undobytes []byte) (*BlockUndo, error) {
+	undoreader := ByteReader{
+		Bytes:  undobytes,
+		Cursor: 0,
+	}
+
+	return ReadBlockUndoFromReader(&undoreader)
+}
+
+// Parses block undo data from a ByteReader
+func ReadBlockUndoFromReader(r *ByteReader) (*BlockUndo, error) {
+	txcount := r.readCount("tx undo count")
+	if r.Err() != nil {
+		return nil, fmt.Errorf("Could not read undo tx count: %w", r.Err())
+	}
+
+	txundos := make([]TxUndo, txcount)
+	i := uint64(0)
+	for i < txcount {
+		coincount := r.readCount("coin count")
+		if r.Err() != nil {
+			return nil, fmt.Errorf("Could not read undo tx %d: %w", i, r.Err())
+		}
+
+		coins := make([]Coin, coincount)
+		j := uint64(0)
+		for j < coincount {
+			coin, err := readUndoCoin(r)
+			if err != nil {
+				return nil, fmt.Errorf("Could not read undo tx %d coin %d: %w", i, j, err)
+			}
+			coins[j] = coin
+			j += 1
+		}
+
+		txundos[i] = TxUndo{
+			PrevOutputs: coins,
+		}
+		i += 1
+	}
+
+	return &BlockUndo{
+		TxUndos: txundos,
+	}, nil
+}
+
+// Reads a spent coin as serialized by bitcoin's TxInUndoFormatter
+func readUndoCoin(r *ByteReader) (Coin, error) {
+	code := r.ReadVarInt() // The height and coinbase flag are combined as height*2 + coinbase
+	height := code >> 1
+	if height > 0 {
+		r.ReadVarInt() // ... followed by a tx version no longer in use, only for spent coins from after block 0
+	}
+	if r.Err() != nil {
+		return Coin{}, r.Err()
+	}
+	if height > 0xFFFFFFFF {
+		return Coin{}, &ReadError{Offset: r.Cursor, Field: "coin height", Err: ErrSizeTooLarge}
+	}
+
+	txout, err := readCompressedTxOutput(r) // ... followed by the compressed output
+	if err != nil {
+		return Coin{}, err
+	}
+
+	return Coin{
+		TxOutput:   txout,
+		Height:     uint32(height),
+		IsCoinbase: code&0x01 == 0x01,
+	}, nil
+}
+
+// Reports whether the record's checksum matches its data, given the hash
+// of the previous block (the parent of the block the undo data is for)
+func (undo *FileUndo) VerifyChecksum(prevBlockHash Hash256) bool {
+	checksum := DoubleSha256(append(append([]byte{}, prevBlockHash...), undo.data...))
+	return bytes.Equal(checksum, undo.Checksum)
+}
+
+// Reads and parses the next undo record of a rev*.dat file. Undo files
+// share their framing, padding and obfuscation with block files. Returns
+// io.EOF once there are no more records
+func (r *BlockFileReader) NextUndo() (*FileUndo, error) {
+	if err := r.skipPadding(); err != nil {
+		return nil, err
+	}
+
+	undo, err := r.ReadUndoAt(r.offset + blockRecordHeaderLength)
+	if err != nil {
+		return nil, err
+	}
+
+	r.offset = undo.Offset + int64(undo.Size) + undoChecksumLength
+	return undo, nil
+}
+
+// Reads and parses the undo record at offset, as found in bitcoin's block
+// index, without moving the reader
+func (r *BlockFileReader) ReadUndoAt(offset int64) (*FileUndo, error) {
+	undobytes, err := r.readRecordAt(offset, undoChecksumLength)
+	if err != nil {
+		return nil, err
+	}
+
+	size := len(undobytes) - undoChecksumLength
+	undo, err := NewBlockUndoFromBytes(undobytes[:size])
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not parse undo data at offset %d: %w", r.Name, offset, err)
+	}
+
+	return &FileUndo{
+		BlockUndo: undo,
+		File:      r.Name,
+		Offset:    offset,
+		Size:      uint32(size),
+		Checksum:  undobytes[size:],
+		data:      undobytes[:size],
+	}, nil
+}
+
+// Attaches the outputs spent by each input of the block, as recovered from
+// the block's undo data, to the PrevOutput of the inputs
+func (block *Block) AttachUndo(undo *BlockUndo) error {
+	if len(block.Transactions) == 0 || len(undo.TxUndos) != len(block.Transactions)-1 {
+		return fmt.Errorf("Undo data for %d txs, block has %d non-coinbase txs: %w", len(undo.TxUndos), len(block.Transactions)-1, ErrUndoMismatch)
+	}
+
+	// Check everything lines up before modifying any inputs
+	for i, txundo := range undo.TxUndos {
+		tx := block.Transactions[i+1]
+		if len(txundo.PrevOutputs) != len(tx.Vin) {
+			return fmt.Errorf("Undo data for %d inputs, tx %s has %d inputs: %w", len(txundo.PrevOutputs), tx.TxId, len(tx.Vin), ErrUndoMismatch)
+		}
+	}
+
+	for i, txundo := range undo.TxUndos {
+		tx := block.Transactions[i+1]
+		for j := range tx.Vin {
+			tx.Vin[j].PrevOutput = &txundo.PrevOutputs[j].TxOutput
+		}
+	}
+	return nil
+}
+
+// Returns the paths of the rev*.dat files in a bitcoin blocks directory,
+// in the order they were written
+func UndoFilePaths(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "rev[0-9][0-9][0-9][0-9][0-9].dat"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
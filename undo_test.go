@@ -0,0 +1,165 @@
+package blockutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+// Builds undo data for dgb6257234, whose two non-coinbase txs spend two
+// and one outputs
+func buildDGB6257234Undo() []byte {
+	hash, _ := hex.DecodeString("bdb2b538e6b07e93d6bafcef4bec9dc936818a19")
+	writer := ByteWriter{}
+	writer.WriteCompactSizeUint(2)
+
+	writer.WriteCompactSizeUint(2)
+	writer.WriteVarInt(6257000 * 2) // height 6257000, not coinbase
+	writer.WriteVarInt(0)
+	writer.WriteVarInt(CompressAmount(100000000))
+	writer.WriteVarInt(0) // P2PKH
+	writer.WriteBytes(hash)
+	writer.WriteVarInt(6257001*2 + 1) // height 6257001, coinbase
+	writer.WriteVarInt(0)
+	writer.WriteVarInt(CompressAmount(123456789))
+	writer.WriteVarInt(numSpecialScripts + 2)
+	writer.WriteBytes([]byte{0x51, 0x87})
+
+	writer.WriteCompactSizeUint(1)
+	writer.WriteVarInt(6257002 * 2)
+	writer.WriteVarInt(0)
+	writer.WriteVarInt(CompressAmount(5000))
+	writer.WriteVarInt(1) // P2SH
+	writer.WriteBytes(hash)
+
+	return writer.Bytes
+}
+
+func TestNewBlockUndoFromBytes(t *testing.T) {
+	undo, err := NewBlockUndoFromBytes(buildDGB6257234Undo())
+	if err != nil {
+		t.Fatalf("Could not parse undo data; %s", err)
+	}
+
+	if len(undo.TxUndos) != 2 {
+		t.Fatalf("Incorrect tx undo count. Expected %d, got %d", 2, len(undo.TxUndos))
+	}
+
+	coin := undo.TxUndos[0].PrevOutputs[1]
+	if coin.Height != 6257001 || !coin.IsCoinbase || coin.Value != 123456789 || coin.Script.String() != "5187" {
+		t.Errorf("Incorrect coin. Expected height %d, coinbase %t, value %d, script %s; got height %d, coinbase %t, value %d, script %s", 6257001, true, 123456789, "5187", coin.Height, coin.IsCoinbase, coin.Value, coin.Script)
+	}
+
+	coin = undo.TxUndos[1].PrevOutputs[0]
+	if coin.Height != 6257002 || coin.IsCoinbase || coin.Value != 5000 || coin.Script.String() != "a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1987" {
+		t.Errorf("Incorrect coin. Expected height %d, coinbase %t, value %d, script %s; got height %d, coinbase %t, value %d, script %s", 6257002, false, 5000, "a914bdb2b538e6b07e93d6bafcef4bec9dc936818a1987", coin.Height, coin.IsCoinbase, coin.Value, coin.Script)
+	}
+
+	undobytes := buildDGB6257234Undo()
+	if _, err := NewBlockUndoFromBytes(undobytes[:len(undobytes)-1]); !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF for truncated undo data, got %v", err)
+	}
+}
+
+func TestBlockAttachUndo(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+	undo, _ := NewBlockUndoFromBytes(buildDGB6257234Undo())
+
+	if err := block.AttachUndo(undo); err != nil {
+		t.Fatalf("Could not attach undo data; %s", err)
+	}
+
+	if block.Transactions[0].Vin[0].PrevOutput != nil {
+		t.Error("Coinbase input should not have a previous output")
+	}
+
+	if block.Transactions[1].Vin[0].PrevOutput.Value != 100000000 {
+		t.Errorf("Incorrect previous output value. Expected %d, got %d", 100000000, block.Transactions[1].Vin[0].PrevOutput.Value)
+	}
+
+	if block.Transactions[2].Vin[0].PrevOutput.Value != 5000 {
+		t.Errorf("Incorrect previous output value. Expected %d, got %d", 5000, block.Transactions[2].Vin[0].PrevOutput.Value)
+	}
+
+	undo.TxUndos = undo.TxUndos[:1]
+	if err := block.AttachUndo(undo); !errors.Is(err, ErrUndoMismatch) {
+		t.Errorf("Expected ErrUndoMismatch, got %v", err)
+	}
+}
+
+func TestBlockFileReaderUndo(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+	undobytes := buildDGB6257234Undo()
+
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(undobytes)))
+	file := append(MagicDigiByte[:], size...)
+	file = append(file, undobytes...)
+	file = append(file, DoubleSha256(append(append([]byte{}, block.PrevBlockHash...), undobytes...))...)
+	file = append(file, make([]byte, 100)...)
+
+	reader := NewBlockFileReader(bytes.NewReader(file), "rev00000.dat", MagicDigiByte)
+	undo, err := reader.NextUndo()
+	if err != nil {
+		t.Fatalf("Could not read undo record; %s", err)
+	}
+
+	if undo.Offset != 8 || undo.Size != uint32(len(undobytes)) {
+		t.Errorf("Incorrect undo record position. Expected offset %d size %d, got offset %d size %d", 8, len(undobytes), undo.Offset, undo.Size)
+	}
+
+	if !undo.VerifyChecksum(block.PrevBlockHash) {
+		t.Error("Undo checksum did not verify")
+	}
+
+	if undo.VerifyChecksum(block.Hash) {
+		t.Error("Undo checksum verified against the wrong block")
+	}
+
+	if _, err := reader.NextUndo(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last undo record, got %v", err)
+	}
+}
+
+// The rev*.dat record bitcoin writes for block 170, whose only
+// non-coinbase tx spends the coinbase of block 9, followed by its checksum
+var btc170UndoRecord = "f9beb4d92600000001011300320511db93e1dcdb8a016b49840f8c53bc1eb68a382e97b1482ecad7b148a6909a5c1825ee89bb31dd49e9d16073ed103186680543e17bba9e5da7b32d449fff94f4"
+var btc170Tx = "0100000001c997a5e56e104102fa209c6a852dd90660a20b2d9c352423edce25857fcd3704000000004847304402204e45e16932b8af514961a1d3a1a25fdf3f4f7732e9d624c6c61548ab5fb8cd410220181522ec8eca07de4860a4acdd12909d831cc56cbbac4622082221a8768d1d0901ffffffff0200ca9a3b00000000434104ae1a62fe09c5f51b13905f07f06b99a2f7159b2225f374cd378d71302fa28414e7aab37397f554a7df5f142c21c1b7303b8a0626f1baded5c72a704f7e6cd84cac00286bee0000000043410411db93e1dcdb8a016b49840f8c53bc1eb68a382e97b1482ecad7b148a6909a5cb2e0eaddfb84ccf9744464f82e160bfa9b8b64f9d4c03f999b8643f656b412a3ac00000000"
+
+func TestBitcoinUndoRecord(t *testing.T) {
+	record, _ := hex.DecodeString(btc170UndoRecord)
+	reader := NewBlockFileReader(bytes.NewReader(record), "rev00000.dat", MagicBitcoin)
+	undo, err := reader.NextUndo()
+	if err != nil {
+		t.Fatalf("Could not read undo record; %s", err)
+	}
+
+	if !undo.VerifyChecksum(hashFromHex("000000002a22cfee1f2c846adbd12b3e183d4f97683f85dad08a79780a84bd55")) {
+		t.Error("Undo checksum did not verify against block 169")
+	}
+
+	if len(undo.TxUndos) != 1 || len(undo.TxUndos[0].PrevOutputs) != 1 {
+		t.Fatalf("Incorrect undo data. Expected 1 tx spending 1 coin, got %+v", undo.TxUndos)
+	}
+
+	// The uncompressed key is restored from its x coordinate and parity
+	expectedScript := "410411db93e1dcdb8a016b49840f8c53bc1eb68a382e97b1482ecad7b148a6909a5cb2e0eaddfb84ccf9744464f82e160bfa9b8b64f9d4c03f999b8643f656b412a3ac"
+	coin := undo.TxUndos[0].PrevOutputs[0]
+	if coin.Height != 9 || !coin.IsCoinbase || coin.Value != 5000000000 || coin.Script.String() != expectedScript {
+		t.Errorf("Incorrect coin. Expected height %d, coinbase %t, value %d, script %s; got height %d, coinbase %t, value %d, script %s", 9, true, 5000000000, expectedScript, coin.Height, coin.IsCoinbase, coin.Value, coin.Script)
+	}
+
+	tx, _ := NewTransactionFromHexString(btc170Tx)
+	block := &Block{Transactions: []*Transaction{{}, tx}}
+	if err := block.AttachUndo(undo.BlockUndo); err != nil {
+		t.Fatalf("Could not attach undo data; %s", err)
+	}
+
+	// The spent coin paid 50 BTC to the key the tx sends its change back to
+	if tx.Vin[0].PrevOutput.Script.String() != tx.Vout[1].Script.String() {
+		t.Errorf("Incorrect previous output script. Expected %s, got %s", tx.Vout[1].Script, tx.Vin[0].PrevOutput.Script)
+	}
+}
@@ -1,9 +1,7 @@
 package blockutils
 
 import (
-	"bytes"
 	"crypto/sha256"
-	"encoding/binary"
 	"encoding/hex"
 	"golang.org/x/crypto/ripemd160"
 	"strings"
@@ -86,12 +84,6 @@ func AllZero(s []byte) bool {
 	return true
 }
 
-func bytesToUInt64(input []byte) (ret uint64) {
-	buf := bytes.NewBuffer(padByteArray(input, 8))
-	binary.Read(buf, binary.LittleEndian, &ret)
-	return ret
-}
-
 func copyFromIndex(input []byte, start uint64, length uint64) []byte {
 	output := make([]byte, length)
 
@@ -103,15 +95,3 @@ func copyFromIndex(input []byte, start uint64, length uint64) []byte {
 
 	return output
 }
-
-// We need to pad the block number bytes to fit uint64
-func padByteArray(input []byte, size int) []byte {
-	l := len(input)
-	if l == size {
-		return input
-	}
-
-	tmp := make([]byte, size)
-	copy(tmp, input)
-	return tmp
-}
@@ -0,0 +1,99 @@
+package blockutils
+
+import (
+	"bytes"
+	"errors"
+)
+
+// The witness commitment is an output of the coinbase whose script is
+// OP_RETURN, a 36 byte push, and these 4 bytes followed by the commitment
+var witnessCommitmentHeader = []byte{0x6a, 0x24, 0xaa, 0x21, 0xa9, 0xed}
+
+const minWitnessCommitmentLength = 38
+
+// Returned when a block's witness commitment does not match the witness
+// data of its transactions
+var ErrWitnessMerkleMismatch = errors.New("Witness commitment does not match witness data")
+
+// Returned when a block has a witness commitment, but the coinbase witness
+// is not a single 32 byte reserved value
+var ErrWitnessReservedValue = errors.New("Invalid witness reserved value")
+
+// Returned when a block without a witness commitment has transactions
+// with witness data
+var ErrUnexpectedWitness = errors.New("Unexpected witness data")
+
+// Computes the merkle root of the block's wtxids, as committed to by
+// segwit blocks (BIP141). The coinbase's wtxid is taken to be all zeros,
+// since the coinbase contains the commitment itself
+func (block *Block) WitnessMerkleRoot() Hash256 {
+	wtxids := make([]Hash256, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		wtxids[i] = tx.WTxId()
+	}
+	if len(wtxids) > 0 {
+		wtxids[0] = make(Hash256, 32)
+	}
+
+	root, _ := MerkleRoot(wtxids)
+	return root
+}
+
+// Returns the witness commitment from the block's coinbase, and whether
+// there is one. If several outputs match, the last one is the commitment
+func (block *Block) WitnessCommitment() (Hash256, bool) {
+	if len(block.Transactions) == 0 {
+		return nil, false
+	}
+
+	coinbase := block.Transactions[0]
+	for i := len(coinbase.Vout) - 1; i >= 0; i-- {
+		script := coinbase.Vout[i].Script
+		if len(script) >= minWitnessCommitmentLength && bytes.HasPrefix(script, witnessCommitmentHeader) {
+			return Hash256(script[len(witnessCommitmentHeader):minWitnessCommitmentLength]), true
+		}
+	}
+	return nil, false
+}
+
+// Returns the witness reserved value, the single 32 byte item of the
+// coinbase's witness, and whether there is a valid one
+func (block *Block) WitnessReservedValue() (Hash256, bool) {
+	if len(block.Transactions) == 0 || len(block.Transactions[0].Vin) == 0 {
+		return nil, false
+	}
+
+	witness := block.Transactions[0].Vin[0].ScriptWitness
+	if len(witness) != 1 || len(witness[0]) != 32 {
+		return nil, false
+	}
+	return witness[0], true
+}
+
+// Checks the block's witness data against the witness commitment in its
+// coinbase, as in bitcoin's CheckWitnessMalleation.
+//
+// The commitment is sha256(sha256(witness merkle root || reserved value)).
+// Blocks without a commitment must not have any witness data.
+func (block *Block) VerifyWitnessCommitment() error {
+	commitment, ok := block.WitnessCommitment()
+	if !ok {
+		for _, tx := range block.Transactions {
+			if tx.HasWitness() {
+				return ErrUnexpectedWitness
+			}
+		}
+		return nil
+	}
+
+	reservedValue, ok := block.WitnessReservedValue()
+	if !ok {
+		return ErrWitnessReservedValue
+	}
+
+	root := block.WitnessMerkleRoot()
+	if !bytes.Equal(DoubleSha256(append(append([]byte{}, root...), reservedValue...)), commitment) {
+		return ErrWitnessMerkleMismatch
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package blockutils
+
+import (
+	"testing"
+)
+
+func TestVerifyWitnessCommitment(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	commitment, ok := block.WitnessCommitment()
+	if !ok {
+		t.Fatal("Could not find witness commitment")
+	}
+
+	if ToHexString(commitment) != "735a4c6d92c7bc860c0558bf0b49feb40e553dffe846613bd6d6bac983473d2c" {
+		t.Errorf("Incorrect witness commitment. Expected %s, got %s", "735a4c6d92c7bc860c0558bf0b49feb40e553dffe846613bd6d6bac983473d2c", ToHexString(commitment))
+	}
+
+	reservedValue, ok := block.WitnessReservedValue()
+	if !ok || !AllZero(reservedValue) {
+		t.Errorf("Incorrect witness reserved value. Expected all zeros, got %x", reservedValue)
+	}
+
+	if err := block.VerifyWitnessCommitment(); err != nil {
+		t.Errorf("Witness commitment did not verify; %s", err)
+	}
+
+	block.Transactions[0].Vin[0].ScriptWitness = WitnessScript{}
+	if err := block.VerifyWitnessCommitment(); err != ErrWitnessReservedValue {
+		t.Errorf("Expected ErrWitnessReservedValue, got %v", err)
+	}
+}
+
+func TestVerifyWitnessCommitmentMismatch(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+
+	// Changing a wtxid changes the witness root, without changing the txids
+	block.Transactions[1].Hash = block.Transactions[2].Hash
+	if err := block.VerifyWitnessCommitment(); err != ErrWitnessMerkleMismatch {
+		t.Errorf("Expected ErrWitnessMerkleMismatch, got %v", err)
+	}
+}
+
+func TestVerifyWitnessCommitmentAbsent(t *testing.T) {
+	block, _ := NewBlockFromHexString(dgb6257234)
+	block.Transactions[0].Vout = block.Transactions[0].Vout[1:]
+
+	if err := block.VerifyWitnessCommitment(); err != ErrUnexpectedWitness {
+		t.Errorf("Expected ErrUnexpectedWitness, got %v", err)
+	}
+
+	block.Transactions[0].Vin[0].ScriptWitness = nil
+	if err := block.VerifyWitnessCommitment(); err != nil {
+		t.Errorf("Block without witness data should verify; %s", err)
+	}
+}